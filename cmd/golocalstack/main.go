@@ -0,0 +1,249 @@
+/*
+Command golocalstack starts, stops, lists and prunes the Localstack containers
+used by go_localstack tests.  It lets developers keep one long-lived
+container that their tests reuse, by name or through LOCALSTACK_ENDPOINT.
+
+Usage
+
+	golocalstack up --services s3,sqs [--name golocalstack] [--repository ...] [--tag latest] [--data dir]
+	golocalstack down [--name golocalstack]
+	golocalstack ls
+	golocalstack logs [--name golocalstack]
+	golocalstack prune [--all]
+	golocalstack env [--name golocalstack]
+
+To point the AWS CLI and the tests in the current shell at the container:
+
+	eval "$(golocalstack env)"
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nichobbs/go_localstack/pkg/localstack"
+)
+
+// defaultName is the name of the container managed when none is given.
+const defaultName = "golocalstack"
+
+// command is a golocalstack sub-command.
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"up":    {"start a named Localstack container, or reuse a running one", up},
+	"down":  {"remove a named Localstack container", down},
+	"ls":    {"list the Localstack containers created by go_localstack", ls},
+	"logs":  {"print the logs of a named Localstack container", logs},
+	"prune": {"remove the Localstack containers left behind by tests, except named, kept and recently started running ones", prune},
+	"env":   {"print the environment pointing AWS tools at a named container", env},
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "golocalstack: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the sub-command named by the first argument.
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage())
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage())
+	}
+	return cmd.run(args[1:], stdout)
+}
+
+// usage lists the sub-commands.
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("usage: golocalstack <command> [flags]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-6s %s\n", name, commands[name].usage)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// newFlagSet returns the flag set of a sub-command.  Errors are returned
+// rather than exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("golocalstack "+name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	return flags
+}
+
+func up(args []string, stdout io.Writer) error {
+	flags := newFlagSet("up")
+	names := flags.String("services", "", "comma separated services to start, e.g. s3,sqs")
+	name := flags.String("name", defaultName, "name of the container")
+	repository := flags.String("repository", localstack.LocalstackRepository, "Localstack image repository")
+	tag := flags.String("tag", "latest", "Localstack image tag")
+	data := flags.String("data", "", "DATA_DIR to persist data in")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	services, err := parseServices(*names)
+	if err != nil {
+		return err
+	}
+
+	// The container is always started with Docker, even when the shell was
+	// pointed at a container by env.
+	os.Unsetenv(localstack.EndpointEnv)
+	os.Unsetenv(localstack.BackendEnv)
+
+	instance, err := localstack.NewPersistentSpecificLocalstack(services, *name, *repository, *tag, *data)
+	if err != nil {
+		return err
+	}
+	// The container is deliberately left running.
+	fmt.Fprintf(stdout, "%s is running at %s\n", *name, instance.Backend().Endpoint())
+	return nil
+}
+
+func down(args []string, stdout io.Writer) error {
+	flags := newFlagSet("down")
+	name := flags.String("name", defaultName, "name of the container")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	container, err := localstack.FindContainer(*name)
+	if err != nil {
+		return err
+	}
+	if err := container.Remove(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s removed\n", container.Name)
+	return nil
+}
+
+func ls(args []string, stdout io.Writer) error {
+	if err := newFlagSet("ls").Parse(args); err != nil {
+		return err
+	}
+	containers, err := localstack.ListContainers()
+	if err != nil {
+		return err
+	}
+	return writeContainers(stdout, containers)
+}
+
+// writeContainers writes a table of the containers.
+func writeContainers(w io.Writer, containers []*localstack.ManagedContainer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tID\tSERVICES\tENDPOINT\tSTATUS\tTEST")
+	for _, c := range containers {
+		id := c.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		endpoint := c.Endpoint
+		if endpoint == "" {
+			endpoint = "-"
+		}
+		test := c.Test
+		if test == "" {
+			test = "-"
+		} else if c.Kept {
+			test += " (kept)"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, id, strings.Join(c.Services, ","), endpoint, c.Status, test)
+	}
+	return table.Flush()
+}
+
+func logs(args []string, stdout io.Writer) error {
+	flags := newFlagSet("logs")
+	name := flags.String("name", defaultName, "name of the container")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	container, err := localstack.FindContainer(*name)
+	if err != nil {
+		return err
+	}
+	return container.Logs(stdout)
+}
+
+func prune(args []string, stdout io.Writer) error {
+	flags := newFlagSet("prune")
+	all := flags.Bool("all", false, "remove named containers and those kept for failed tests too")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	pruned, err := localstack.PruneContainers(*all)
+	for _, container := range pruned {
+		fmt.Fprintf(stdout, "%s removed\n", container.Name)
+	}
+	return err
+}
+
+func env(args []string, stdout io.Writer) error {
+	flags := newFlagSet("env")
+	name := flags.String("name", defaultName, "name of the container")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	container, err := localstack.FindContainer(*name)
+	if err != nil {
+		return err
+	}
+	if !container.Running || container.Endpoint == "" {
+		return fmt.Errorf("%s is not running", container.Name)
+	}
+	writeEnv(stdout, container.Env())
+	return nil
+}
+
+// writeEnv writes the variables as shell exports, sorted by name.
+func writeEnv(w io.Writer, variables map[string]string) {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "export %s=%s\n", name, variables[name])
+	}
+}
+
+// parseServices parses a comma separated list of service names.
+func parseServices(names string) (*localstack.LocalstackServiceCollection, error) {
+	services := &localstack.LocalstackServiceCollection{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		service, err := localstack.NewLocalstackService(name)
+		if err != nil {
+			return nil, err
+		}
+		*services = append(*services, *service)
+	}
+	if len(*services) == 0 {
+		return nil, errors.New("no services given, use --services s3,sqs")
+	}
+	return services, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nichobbs/go_localstack/pkg/localstack"
+)
+
+func Test_Run_UnknownCommand(t *testing.T) {
+	if err := run(nil, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("We were expecting the usage.  Received %v", err)
+	}
+	if err := run([]string{"start"}, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "prune") {
+		t.Errorf("We were expecting the commands to be listed.  Received %v", err)
+	}
+	if err := run([]string{"up", "--services", "s4"}, new(bytes.Buffer)); err == nil {
+		t.Error("We were expecting an unknown service to be rejected.")
+	}
+}
+
+func Test_ParseServices(t *testing.T) {
+	services, err := parseServices("s3, sqs,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !services.Contains("s3") || !services.Contains("sqs") || len(*services) != 2 {
+		t.Errorf("We were expecting s3 and sqs.  Received %v", services)
+	}
+	if _, err := parseServices(""); err == nil {
+		t.Error("We were expecting no services to be rejected.")
+	}
+}
+
+func Test_WriteContainers(t *testing.T) {
+	output := new(bytes.Buffer)
+	err := writeContainers(output, []*localstack.ManagedContainer{{
+		ID:       "0123456789abcdef",
+		Name:     "dev",
+		Services: []string{"s3", "sqs"},
+		Endpoint: "http://localhost:32768",
+		Status:   "Up 5 minutes",
+	}, {
+		ID:     "fedcba",
+		Name:   "stopped",
+		Status: "Exited (0)",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("We were expecting a header and two rows.  Received %q", output)
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields[:4], " ") != "dev 0123456789ab s3,sqs http://localhost:32768" {
+		t.Errorf("The container was not listed correctly.  Received %q", lines[1])
+	}
+	if !strings.Contains(lines[2], " - ") {
+		t.Errorf("A missing endpoint should be shown as -.  Received %q", lines[2])
+	}
+}
+
+func Test_WriteEnv(t *testing.T) {
+	output := new(bytes.Buffer)
+	writeEnv(output, map[string]string{"B": "2", "A": "1"})
+	if output.String() != "export A=1\nexport B=2\n" {
+		t.Errorf("We were expecting sorted exports.  Received %q", output)
+	}
+}
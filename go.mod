@@ -17,4 +17,5 @@ require (
 	github.com/opencontainers/runc v0.1.1 // indirect
 	github.com/ory/dockertest v3.3.5+incompatible
 	github.com/sirupsen/logrus v1.6.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
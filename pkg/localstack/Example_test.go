@@ -47,8 +47,12 @@ func Example_s3() {
 	//nolint:errcheck
 	defer LOCALSTACK.Destroy()
 
-	// Here we start the code to interact with S3
-	svc := s3.New(LOCALSTACK.CreateAWSSession())
+	// Here we start the code to interact with S3.  The client shares
+	// the session cached on the Localstack instance.
+	svc, err := LOCALSTACK.S3()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Create Bucket
 	input := &s3.CreateBucketInput{
@@ -64,7 +68,11 @@ func Example_s3() {
 	}
 
 	//Upload File
-	uploader := s3manager.NewUploader(LOCALSTACK.CreateAWSSession())
+	sess, err := LOCALSTACK.Session()
+	if err != nil {
+		log.Fatal(err)
+	}
+	uploader := s3manager.NewUploader(sess)
 	_, err = uploader.Upload(&s3manager.UploadInput{
 		Bucket: aws.String("examplebucket"),
 		Key:    aws.String("examplefile"),
@@ -0,0 +1,94 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Backend runs the Localstack instance AWS calls are sent to.  By default it
+// is a Docker container started with dockertest.  MemoryBackend,
+// ExternalBackend and FakeBackend can stand in for it.  See WithBackend.
+type Backend interface {
+	// Start starts the backend for the requested services and returns once
+	// they are ready.
+	Start(services *LocalstackServiceCollection) error
+	// Inspect describes the current state of the backend.
+	Inspect() (*ContainerInfo, error)
+	// Logs writes the output of the backend so far to w.
+	Logs(w io.Writer) error
+	// Exec runs cmd inside the backend, writes its output to w and returns
+	// its exit code.
+	Exec(cmd []string, w io.Writer) (int, error)
+	// Stop stops the backend.
+	Stop() error
+	// Endpoint returns the URL AWS calls are sent to.
+	Endpoint() string
+}
+
+// ContainerInfo describes the container, or whatever stands in for it,
+// behind a Backend.
+type ContainerInfo struct {
+	// ID is the container ID, if any.
+	ID string
+	// Name is the container name, or a description of the backend.
+	Name string
+	// Image is the image the container was created from, if any.
+	Image string
+	// Running is true while the backend can serve requests.
+	Running bool
+	// ExitCode is the exit code of a stopped container.
+	ExitCode int
+	// OOMKilled is true when the container was killed for running out of
+	// memory.
+	OOMKilled bool
+	// Error is the error Docker reported for the container, if any.
+	Error string
+}
+
+// ErrNotSupported is returned by backends that can't do what was asked of
+// them, for example reading the logs of an external Localstack.
+var ErrNotSupported = errors.New("not supported by this backend")
+
+// BackendEnv is the environment variable that chooses the backend of instances
+// created without WithBackend.  It can be set to "docker", the default, or
+// "memory" for the in-process MemoryBackend.
+const BackendEnv = "LOCALSTACK_BACKEND"
+
+// EndpointEnv is the environment variable that attaches instances created
+// without WithBackend to an already running Localstack, for example a CI
+// service container.  It is set to the edge URL, e.g. http://localhost:4566,
+// and takes precedence over BackendEnv.  See Attach.
+const EndpointEnv = "LOCALSTACK_ENDPOINT"
+
+// WithBackend runs the instance against the given backend instead of a
+// Localstack Docker container.
+func WithBackend(backend Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// Backend returns the backend the instance runs on, or nil when a cassette
+// is replayed.
+func (ls *Localstack) Backend() Backend {
+	return ls.backend
+}
+
+// backendFromEnv returns the backend chosen by EndpointEnv or BackendEnv, or
+// nil for Docker.
+func backendFromEnv() (Backend, error) {
+	if endpoint := os.Getenv(EndpointEnv); endpoint != "" {
+		return NewExternalBackend(endpoint), nil
+	}
+	switch value := strings.ToLower(os.Getenv(BackendEnv)); value {
+	case "", "docker":
+		return nil, nil
+	case "memory":
+		return NewMemoryBackend(), nil
+	default:
+		return nil, fmt.Errorf("invalid %s %q, expected docker or memory", BackendEnv, value)
+	}
+}
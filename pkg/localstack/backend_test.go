@@ -0,0 +1,196 @@
+package localstack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nichobbs/go_localstack/pkg/mock_localstack"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_FakeBackend(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	fake := &FakeBackend{
+		Output: "Ready.",
+		ExecFunc: func(cmd []string, w io.Writer) (int, error) {
+			fmt.Fprint(w, "done")
+			return 3, nil
+		},
+	}
+
+	ls, err := NewLocalstack(services, WithBackend(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.Services() != services {
+		t.Error("The backend should be started for the requested services.")
+	}
+	if ls.Backend() != fake || ls.Resource() != nil {
+		t.Errorf("We were expecting the fake backend and no resource.  Received %v %v", ls.Backend(), ls.Resource())
+	}
+
+	svc, _ := ls.S3()
+	if _, err := svc.ListBuckets(nil); awsErrorCode(err) != "UnsupportedOperation" {
+		t.Errorf("Calls should fail without a handler.  Received %v", err)
+	}
+
+	output := new(bytes.Buffer)
+	code, err := ls.Backend().Exec([]string{"awslocal", "s3", "ls"}, output)
+	if err != nil || code != 3 || output.String() != "done" {
+		t.Errorf("The command was not run.  Received %d %q %v", code, output, err)
+	}
+	if commands := fake.Commands(); len(commands) != 1 || commands[0][0] != "awslocal" {
+		t.Errorf("The command was not recorded.  Received %v", commands)
+	}
+
+	if err := ls.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := fake.Inspect(); !fake.Stopped() || info.Running {
+		t.Error("The backend should be stopped on Destroy.")
+	}
+
+	dummy := errors.New("dummy Error")
+	if _, err := NewLocalstack(services, WithBackend(&FakeBackend{StartErr: dummy})); !errors.Is(err, dummy) {
+		t.Errorf("We were expecting the start error to be returned.  Received %v", err)
+	}
+}
+
+func Test_ExternalBackend(t *testing.T) {
+	health := `{"services": {"s3": "running", "sqs": "error"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, health)
+	}))
+	defer server.Close()
+
+	s3, _ := NewLocalstackService("s3")
+	sqs, _ := NewLocalstackService("sqs")
+	sns, _ := NewLocalstackService("sns")
+
+	backend := NewExternalBackend(server.URL + "/")
+	backend.timeout = 0
+	if err := backend.Start(&LocalstackServiceCollection{*s3}); err != nil {
+		t.Errorf("We were expecting a running service to be accepted.  Received %v", err)
+	}
+	if err := backend.Start(&LocalstackServiceCollection{*sqs}); err == nil {
+		t.Error("We were expecting a failed service to be rejected.")
+	}
+	if err := backend.Start(&LocalstackServiceCollection{*sns}); err == nil {
+		t.Error("We were expecting a disabled service to be rejected.")
+	}
+	if backend.Endpoint() != server.URL {
+		t.Errorf("The trailing slash should be removed.  Received %s", backend.Endpoint())
+	}
+
+	// Versions without a health report are only checked for connectivity.
+	health = "not json"
+	if err := backend.Start(&LocalstackServiceCollection{*sns}); err != nil {
+		t.Errorf("We were expecting any response to be accepted.  Received %v", err)
+	}
+	if err := backend.Logs(new(bytes.Buffer)); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("We were expecting ErrNotSupported.  Received %v", err)
+	}
+
+	server.Close()
+	if info, _ := backend.Inspect(); info.Running {
+		t.Error("We were expecting the backend to be reported as down.")
+	}
+	if err := backend.Start(&LocalstackServiceCollection{*s3}); err == nil {
+		t.Error("We were expecting an unreachable localstack to be rejected.")
+	}
+}
+
+func Test_Attach(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The first check finds the service still starting.
+		if calls == 1 {
+			fmt.Fprint(w, `{"services": {"s3": "initializing"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"services": {"s3": "running"}}`)
+	}))
+	defer server.Close()
+
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	ls, err := Attach(server.URL, services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("We were expecting the health to be checked until ready.  Received %d checks", calls)
+	}
+	if ep, _ := ls.EndpointFor("s3", "us-east-1"); ep.URL != server.URL {
+		t.Errorf("The attached endpoint should be used.  Received %s", ep.URL)
+	}
+	if err := ls.Destroy(); err != nil {
+		t.Error(err)
+	}
+
+	os.Setenv(EndpointEnv, server.URL)
+	defer os.Unsetenv(EndpointEnv)
+	ls, err = NewLocalstack(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend, ok := ls.Backend().(*ExternalBackend); !ok || backend.Endpoint() != server.URL {
+		t.Errorf("The environment should attach to the endpoint.  Received %v", ls.Backend())
+	}
+}
+
+func Test_DockertestBackend_Exec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	backend := &dockertestBackend{
+		wrapper:  m,
+		resource: &dockertest.Resource{Container: &docker.Container{ID: "container"}},
+	}
+
+	m.
+		EXPECT().
+		CreateExec(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts docker.CreateExecOptions) (*docker.Exec, error) {
+			if opts.Container != "container" || len(opts.Cmd) != 2 {
+				t.Errorf("The exec was not created correctly.  Received %v", opts)
+			}
+			return &docker.Exec{ID: "exec"}, nil
+		})
+	m.
+		EXPECT().
+		StartExec("exec", gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ string, opts docker.StartExecOptions) error {
+			fmt.Fprint(opts.OutputStream, "output")
+			return nil
+		})
+	m.
+		EXPECT().
+		InspectExec("exec").
+		Times(1).
+		Return(&docker.ExecInspect{ExitCode: 1}, nil)
+
+	output := new(bytes.Buffer)
+	code, err := backend.Exec([]string{"ls", "/tmp"}, output)
+	if err != nil || code != 1 || output.String() != "output" {
+		t.Errorf("We were expecting the exit code and output.  Received %d %q %v", code, output, err)
+	}
+}
@@ -0,0 +1,395 @@
+package localstack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteMode chooses whether a cassette is recorded or replayed.
+type CassetteMode int
+
+const (
+	// CassetteAuto takes the mode from the LOCALSTACK_CASSETTE_MODE environment
+	// variable.  When it isn't set, the cassette is replayed if the file exists
+	// and recorded otherwise.
+	CassetteAuto CassetteMode = iota
+	// CassetteRecord runs against a Localstack container and records every
+	// call to the cassette.
+	CassetteRecord
+	// CassetteReplay serves the recorded responses from an in-process server.
+	// No Docker container is started.
+	CassetteReplay
+)
+
+// CassetteModeEnv is the environment variable read by CassetteAuto.  It can be
+// set to "record" or "replay".
+const CassetteModeEnv = "LOCALSTACK_CASSETTE_MODE"
+
+// String returns the name of the mode.
+func (mode CassetteMode) String() string {
+	switch mode {
+	case CassetteRecord:
+		return "record"
+	case CassetteReplay:
+		return "replay"
+	default:
+		return "auto"
+	}
+}
+
+// ErrCassetteMismatch is returned when a replayed request wasn't recorded in
+// the cassette.
+var ErrCassetteMismatch = errors.New("request not recorded in cassette")
+
+// cassetteMismatchCode is the AWS error code returned for unrecorded requests.
+const cassetteMismatchCode = "CassetteMismatch"
+
+// edgePlaceholder stands in for the address of the proxy in recorded
+// interactions, as it changes between runs.
+const edgePlaceholder = "{{edge}}"
+
+// idempotencyParams are filled with random values by the AWS SDK, so they are
+// ignored when matching requests.
+var idempotencyParams = []string{"ClientRequestToken", "ClientToken", "IdempotencyToken"}
+
+// skippedHeaders are response headers that aren't recorded.  The CRC32 of
+// DynamoDB responses no longer holds once the edge address is rewritten.
+var skippedHeaders = []string{"Content-Length", "Date", "Server", "Connection", "Transfer-Encoding", "X-Amz-Crc32"}
+
+// Interaction is a request recorded in a cassette along with its response.
+type Interaction struct {
+	Request  InteractionRequest  `json:"request"`
+	Response InteractionResponse `json:"response"`
+}
+
+// InteractionRequest describes a recorded request.  Replayed requests are
+// matched on every field.
+type InteractionRequest struct {
+	Service   string                 `json:"service"`
+	Operation string                 `json:"operation"`
+	Method    string                 `json:"method"`
+	Path      string                 `json:"path"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+}
+
+// InteractionResponse is a recorded response.
+type InteractionResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is the response body, base64 encoded when Base64 is true.
+	Body   string `json:"body,omitempty"`
+	Base64 bool   `json:"base64,omitempty"`
+}
+
+// cassetteFile is the format of a cassette on disk.
+type cassetteFile struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Cassette holds the interactions recorded from, or replayed in place of, a
+// Localstack instance.  See WithCassette.
+type Cassette struct {
+	path string
+	mode CassetteMode
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	mismatches   []string
+}
+
+// WithCassette records the AWS calls made through the instance's sessions to
+// the cassette file at path, or replays them from it.  When replaying, no
+// Docker container is started and the recorded responses are served by an
+// in-process server instead.  Requests that weren't recorded fail with a
+// CassetteMismatch AWS error, and Destroy returns an error listing them.
+func WithCassette(path string, mode CassetteMode) Option {
+	return func(o *options) {
+		o.cassettePath = path
+		o.cassetteMode = mode
+	}
+}
+
+// resolveCassetteMode works out the mode of CassetteAuto.
+func resolveCassetteMode(path string, mode CassetteMode) (CassetteMode, error) {
+	if mode != CassetteAuto {
+		return mode, nil
+	}
+	switch value := strings.ToLower(os.Getenv(CassetteModeEnv)); value {
+	case "record":
+		return CassetteRecord, nil
+	case "replay":
+		return CassetteReplay, nil
+	case "":
+		if _, err := os.Stat(path); err == nil {
+			return CassetteReplay, nil
+		}
+		return CassetteRecord, nil
+	default:
+		return CassetteAuto, fmt.Errorf("invalid %s %q, expected record or replay", CassetteModeEnv, value)
+	}
+}
+
+// openCassette opens the cassette at path, loading it when it is replayed.
+func openCassette(path string, mode CassetteMode) (*Cassette, error) {
+	mode, err := resolveCassetteMode(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{path: path, mode: mode}
+	if mode != CassetteReplay {
+		return c, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cassette %s, record it first with %s=record: %s", path, CassetteModeEnv, err)
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse cassette %s: %s", path, err)
+	}
+	c.interactions = file.Interactions
+	c.used = make([]bool, len(file.Interactions))
+	return c, nil
+}
+
+// Path returns the path of the cassette file.
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+// Mode returns whether the cassette is being recorded or replayed.
+func (c *Cassette) Mode() CassetteMode {
+	return c.mode
+}
+
+// Interactions returns a copy of the interactions in the cassette.
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	interactions := make([]Interaction, len(c.interactions))
+	copy(interactions, c.interactions)
+	return interactions
+}
+
+// Mismatches describes every replayed request that wasn't recorded.
+func (c *Cassette) Mismatches() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mismatches := make([]string, len(c.mismatches))
+	copy(mismatches, c.mismatches)
+	return mismatches
+}
+
+// record adds a forwarded call and its response to the cassette.  host is the
+// address of the proxy the call was made to.
+func (c *Cassette) record(host string, call Call, header http.Header, status int, body []byte) {
+	response := InteractionResponse{Status: status, Header: http.Header{}}
+	for key, values := range header {
+		if skipHeader(key) {
+			continue
+		}
+		for _, value := range values {
+			response.Header.Add(key, strings.ReplaceAll(value, host, edgePlaceholder))
+		}
+	}
+	if utf8.Valid(body) {
+		response.Body = strings.ReplaceAll(string(body), host, edgePlaceholder)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.Base64 = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, Interaction{
+		Request:  describeInteraction(host, call),
+		Response: response,
+	})
+}
+
+// replay serves the recorded response matching the request.
+func (c *Cassette) replay(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	call := describeCall(r, body)
+
+	interaction, err := c.match(describeInteraction(r.Host, call))
+	if err != nil {
+		writeAWSError(w, &call, http.StatusBadRequest, cassetteMismatchCode, err.Error())
+		return
+	}
+
+	response := interaction.Response
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, strings.ReplaceAll(value, edgePlaceholder, r.Host))
+		}
+	}
+	contents := []byte(strings.ReplaceAll(response.Body, edgePlaceholder, r.Host))
+	if response.Base64 {
+		if contents, err = base64.StdEncoding.DecodeString(response.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(response.Status)
+	w.Write(contents) //nolint:errcheck
+}
+
+// match returns the first unused interaction matching the request.  Once every
+// matching interaction has been used, the last is replayed again so polling
+// for a result keeps working.
+func (c *Cassette) match(request InteractionRequest) (*Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var last *Interaction
+	for i := range c.interactions {
+		if !sameRequest(c.interactions[i].Request, request) {
+			continue
+		}
+		if !c.used[i] {
+			c.used[i] = true
+			return &c.interactions[i], nil
+		}
+		last = &c.interactions[i]
+	}
+	if last != nil {
+		return last, nil
+	}
+
+	mismatch := fmt.Sprintf("%s %s (%s %s) with params %s", request.Service, request.Operation,
+		request.Method, request.Path, formatParams(request.Params))
+	var recorded []string
+	for _, interaction := range c.interactions {
+		if interaction.Request.Service == request.Service && interaction.Request.Operation == request.Operation {
+			recorded = append(recorded, formatParams(interaction.Request.Params))
+		}
+	}
+	if len(recorded) == 0 {
+		mismatch += ", the cassette has no such calls"
+	} else {
+		mismatch += fmt.Sprintf(", the cassette only has it with params %s", strings.Join(recorded, " or "))
+	}
+	c.mismatches = append(c.mismatches, mismatch)
+	return nil, fmt.Errorf("%s: %w", mismatch, ErrCassetteMismatch)
+}
+
+// finish writes a recorded cassette to disk, or returns an error listing the
+// requests that didn't match a replayed cassette.
+func (c *Cassette) finish() error {
+	if c.mode == CassetteReplay {
+		mismatches := c.Mismatches()
+		if len(mismatches) == 0 {
+			return nil
+		}
+		return fmt.Errorf("cassette %s: %d requests were not recorded:\n  %s", c.path, len(mismatches),
+			strings.Join(mismatches, "\n  "))
+	}
+
+	contents, err := json.MarshalIndent(cassetteFile{Version: 1, Interactions: c.Interactions()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode cassette %s: %s", c.path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("unable to create cassette directory for %s: %s", c.path, err)
+	}
+	if err := ioutil.WriteFile(c.path, contents, 0644); err != nil { //nolint:gosec
+		return fmt.Errorf("unable to write cassette %s: %s", c.path, err)
+	}
+	return nil
+}
+
+// describeInteraction describes a call the way it is stored in a cassette: the
+// proxy address is replaced and the parameters take their JSON form.
+func describeInteraction(host string, call Call) InteractionRequest {
+	request := InteractionRequest{
+		Service:   call.Service,
+		Operation: call.Operation,
+		Method:    call.Method,
+		Path:      call.Path,
+	}
+
+	var params map[string]interface{}
+	if contents, err := json.Marshal(call.Params); err == nil {
+		json.Unmarshal(contents, &params) //nolint:errcheck
+	}
+	for _, name := range idempotencyParams {
+		delete(params, name)
+	}
+	if len(params) > 0 {
+		request.Params = replaceHost(params, host).(map[string]interface{})
+	}
+	return request
+}
+
+// replaceHost replaces the host in every string of a decoded JSON value.
+func replaceHost(value interface{}, host string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.ReplaceAll(v, host, edgePlaceholder)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = replaceHost(item, host)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = replaceHost(item, host)
+		}
+	}
+	return value
+}
+
+// sameRequest returns true when a replayed request matches a recorded one.
+func sameRequest(recorded, request InteractionRequest) bool {
+	return recorded.Service == request.Service &&
+		recorded.Operation == request.Operation &&
+		recorded.Method == request.Method &&
+		recorded.Path == request.Path &&
+		(len(recorded.Params) == 0 && len(request.Params) == 0 || reflect.DeepEqual(recorded.Params, request.Params))
+}
+
+func skipHeader(key string) bool {
+	for _, skipped := range skippedHeaders {
+		if strings.EqualFold(key, skipped) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatParams(params map[string]interface{}) string {
+	if len(params) == 0 {
+		return "{}"
+	}
+	contents, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprint(params)
+	}
+	return string(contents)
+}
+
+// Cassette returns the cassette of the instance, or nil when no cassette was
+// requested.  See WithCassette.
+func (ls *Localstack) Cassette() *Cassette {
+	if ls.proxy == nil {
+		return nil
+	}
+	return ls.proxy.cassette
+}
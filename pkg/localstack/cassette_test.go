@@ -0,0 +1,178 @@
+package localstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/golang/mock/gomock"
+	"github.com/nichobbs/go_localstack/pkg/mock_localstack"
+)
+
+// fakeEdge answers CreateQueue with a queue url on the requested host, and
+// every other call with a ListTables response.
+func fakeEdge() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "" {
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<CreateQueueResponse><CreateQueueResult><QueueUrl>http://%s/000000000000/jobs</QueueUrl>`+
+				`</CreateQueueResult></CreateQueueResponse>`, r.Host)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"TableNames":["users"]}`)) //nolint:errcheck
+	}))
+}
+
+func Test_Cassette_RecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassettes", "suite.json")
+
+	dynamo, _ := NewLocalstackService("dynamodb")
+	queues, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{*dynamo, *queues}
+
+	// Record against the fake edge.
+	edge := fakeEdge()
+	defer edge.Close()
+	cassette, err := openCassette(path, CassetteRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newEdgeProxy(mustReverseProxy(t, edge.URL), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.cassette = cassette
+	recorded := &Localstack{backend: newTestBackend(), Services: services, proxy: p}
+
+	if _, err := dynamodb.New(testSession(t, recorded)).ListTables(&dynamodb.ListTablesInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqs.New(testSession(t, recorded)).CreateQueue(&sqs.CreateQueueInput{QueueName: aws.String("jobs")}); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if err := cassette.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(contents), p.listener.Addr().String()) {
+		t.Error("The proxy address should not be recorded.")
+	}
+
+	// Replay without docker, the mock fails the test if it is used.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ls, err := newLocalstack(services, mock_localstack.NewMockDockerWrapper(ctrl), "", "", "",
+		WithCassette(path, CassetteReplay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := dynamodb.New(testSession(t, ls)).ListTables(&dynamodb.ListTablesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables.TableNames) != 1 || aws.StringValue(tables.TableNames[0]) != "users" {
+		t.Errorf("We were expecting the recorded tables.  Received %v", tables)
+	}
+
+	queue, err := sqs.New(testSession(t, ls)).CreateQueue(&sqs.CreateQueueInput{QueueName: aws.String("jobs")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(queue.QueueUrl) != ls.edgeURL()+"/000000000000/jobs" {
+		t.Errorf("The queue url should point at the replay server.  Received %s", aws.StringValue(queue.QueueUrl))
+	}
+
+	_, err = sqs.New(testSession(t, ls)).CreateQueue(&sqs.CreateQueueInput{QueueName: aws.String("other")})
+	if awsErrorCode(err) != cassetteMismatchCode || !strings.Contains(err.Error(), `"QueueName":"jobs"`) {
+		t.Errorf("We were expecting a mismatch naming the recorded queue.  Received %v", err)
+	}
+
+	err = ls.Destroy()
+	if err == nil || !strings.Contains(err.Error(), `"QueueName":"other"`) {
+		t.Errorf("Destroy should report the unrecorded request.  Received %v", err)
+	}
+}
+
+func Test_Cassette_Replay_NoBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "suite.json")
+	if err := ioutil.WriteFile(path, []byte(`{"version":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lambda, _ := NewLocalstackService("lambda")
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ls, err := newLocalstack(&LocalstackServiceCollection{*lambda}, mock_localstack.NewMockDockerWrapper(ctrl), "", "", "",
+		WithCassette(path, CassetteReplay), WithLambdaDockerExecutor(LambdaDockerConfig{}))
+	if err != nil {
+		t.Fatalf("We were expecting the docker check to be skipped when replaying.  Received %v", err)
+	}
+	defer ls.Destroy()
+
+	if ls.containerURL() != ls.proxy.URL() {
+		t.Errorf("We were expecting the proxy to stand in for the container.  Received %s", ls.containerURL())
+	}
+}
+
+func Test_Cassette_Mode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "suite.json")
+
+	os.Unsetenv(CassetteModeEnv)
+	if mode, _ := resolveCassetteMode(path, CassetteAuto); mode != CassetteRecord {
+		t.Errorf("A missing cassette should be recorded.  Received %s", mode)
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"version":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if mode, _ := resolveCassetteMode(path, CassetteAuto); mode != CassetteReplay {
+		t.Errorf("An existing cassette should be replayed.  Received %s", mode)
+	}
+
+	os.Setenv(CassetteModeEnv, "record")
+	defer os.Unsetenv(CassetteModeEnv)
+	if mode, _ := resolveCassetteMode(path, CassetteAuto); mode != CassetteRecord {
+		t.Errorf("The environment should choose the mode.  Received %s", mode)
+	}
+	if mode, _ := resolveCassetteMode(path, CassetteReplay); mode != CassetteReplay {
+		t.Errorf("An explicit mode should win.  Received %s", mode)
+	}
+
+	os.Setenv(CassetteModeEnv, "rewind")
+	if _, err := resolveCassetteMode(path, CassetteAuto); err == nil {
+		t.Error("We were expecting an invalid mode to fail.")
+	}
+
+	if _, err := openCassette(filepath.Join(dir, "missing.json"), CassetteReplay); err == nil {
+		t.Error("We were expecting a missing cassette to fail when replayed.")
+	}
+}
@@ -0,0 +1,244 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/elasticsearchservice"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/redshift"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ErrServiceNotRequested is returned by the client accessors when the service
+// wasn't requested from the Localstack instance.  Without the check, the
+// client would quietly send its traffic to the real AWS endpoint.
+var ErrServiceNotRequested = errors.New("service was not requested from localstack")
+
+// Session returns an AWS session routed to the Localstack instance.  Unlike
+// NewAWSSession, the session, or the error creating it, is cached on the
+// instance.
+func (ls *Localstack) Session() (*session.Session, error) {
+	state := ls.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.session == nil && state.sessionErr == nil {
+		state.session, state.sessionErr = ls.NewAWSSession()
+	}
+	return state.session, state.sessionErr
+}
+
+// sessionFor returns the cached session after checking the service was requested.
+func (ls *Localstack) sessionFor(name string) (*session.Session, error) {
+	if ls.Services == nil || !ls.Services.Contains(name) {
+		return nil, fmt.Errorf("%s: %w", name, ErrServiceNotRequested)
+	}
+	return ls.Session()
+}
+
+// APIGateway returns a client for the API Gateway service of the Localstack instance.
+func (ls *Localstack) APIGateway() (*apigateway.APIGateway, error) {
+	sess, err := ls.sessionFor("apigateway")
+	if err != nil {
+		return nil, err
+	}
+	return apigateway.New(sess), nil
+}
+
+// CloudFormation returns a client for the CloudFormation service of the Localstack instance.
+func (ls *Localstack) CloudFormation() (*cloudformation.CloudFormation, error) {
+	sess, err := ls.sessionFor("cloudformation")
+	if err != nil {
+		return nil, err
+	}
+	return cloudformation.New(sess), nil
+}
+
+// CloudWatch returns a client for the CloudWatch service of the Localstack instance.
+func (ls *Localstack) CloudWatch() (*cloudwatch.CloudWatch, error) {
+	sess, err := ls.sessionFor("cloudwatch")
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatch.New(sess), nil
+}
+
+// CloudWatchLogs returns a client for the CloudWatch Logs service of the Localstack instance.
+func (ls *Localstack) CloudWatchLogs() (*cloudwatchlogs.CloudWatchLogs, error) {
+	sess, err := ls.sessionFor("logs")
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatchlogs.New(sess), nil
+}
+
+// DynamoDB returns a client for the DynamoDB service of the Localstack instance.
+func (ls *Localstack) DynamoDB() (*dynamodb.DynamoDB, error) {
+	sess, err := ls.sessionFor("dynamodb")
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.New(sess), nil
+}
+
+// DynamoDBStreams returns a client for the DynamoDB Streams service of the Localstack instance.
+func (ls *Localstack) DynamoDBStreams() (*dynamodbstreams.DynamoDBStreams, error) {
+	sess, err := ls.sessionFor("dynamodbstreams")
+	if err != nil {
+		return nil, err
+	}
+	return dynamodbstreams.New(sess), nil
+}
+
+// ES returns a client for the Elasticsearch Service service of the Localstack instance.
+func (ls *Localstack) ES() (*elasticsearchservice.ElasticsearchService, error) {
+	sess, err := ls.sessionFor("es")
+	if err != nil {
+		return nil, err
+	}
+	return elasticsearchservice.New(sess), nil
+}
+
+// Firehose returns a client for the Kinesis Firehose service of the Localstack instance.
+func (ls *Localstack) Firehose() (*firehose.Firehose, error) {
+	sess, err := ls.sessionFor("firehose")
+	if err != nil {
+		return nil, err
+	}
+	return firehose.New(sess), nil
+}
+
+// IAM returns a client for the IAM service of the Localstack instance.
+func (ls *Localstack) IAM() (*iam.IAM, error) {
+	sess, err := ls.sessionFor("iam")
+	if err != nil {
+		return nil, err
+	}
+	return iam.New(sess), nil
+}
+
+// Kinesis returns a client for the Kinesis service of the Localstack instance.
+func (ls *Localstack) Kinesis() (*kinesis.Kinesis, error) {
+	sess, err := ls.sessionFor("kinesis")
+	if err != nil {
+		return nil, err
+	}
+	return kinesis.New(sess), nil
+}
+
+// Lambda returns a client for the Lambda service of the Localstack instance.
+func (ls *Localstack) Lambda() (*lambda.Lambda, error) {
+	sess, err := ls.sessionFor("lambda")
+	if err != nil {
+		return nil, err
+	}
+	return lambda.New(sess), nil
+}
+
+// Redshift returns a client for the Redshift service of the Localstack instance.
+func (ls *Localstack) Redshift() (*redshift.Redshift, error) {
+	sess, err := ls.sessionFor("redshift")
+	if err != nil {
+		return nil, err
+	}
+	return redshift.New(sess), nil
+}
+
+// Route53 returns a client for the Route 53 service of the Localstack instance.
+func (ls *Localstack) Route53() (*route53.Route53, error) {
+	sess, err := ls.sessionFor("route53")
+	if err != nil {
+		return nil, err
+	}
+	return route53.New(sess), nil
+}
+
+// S3 returns a client for the S3 service of the Localstack instance.
+func (ls *Localstack) S3() (*s3.S3, error) {
+	sess, err := ls.sessionFor("s3")
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(sess), nil
+}
+
+// SES returns a client for the SES service of the Localstack instance.
+func (ls *Localstack) SES() (*ses.SES, error) {
+	sess, err := ls.sessionFor("ses")
+	if err != nil {
+		return nil, err
+	}
+	return ses.New(sess), nil
+}
+
+// SNS returns a client for the SNS service of the Localstack instance.
+func (ls *Localstack) SNS() (*sns.SNS, error) {
+	sess, err := ls.sessionFor("sns")
+	if err != nil {
+		return nil, err
+	}
+	return sns.New(sess), nil
+}
+
+// SQS returns a client for the SQS service of the Localstack instance.
+func (ls *Localstack) SQS() (*sqs.SQS, error) {
+	sess, err := ls.sessionFor("sqs")
+	if err != nil {
+		return nil, err
+	}
+	return sqs.New(sess), nil
+}
+
+// SSM returns a client for the SSM service of the Localstack instance.
+func (ls *Localstack) SSM() (*ssm.SSM, error) {
+	sess, err := ls.sessionFor("ssm")
+	if err != nil {
+		return nil, err
+	}
+	return ssm.New(sess), nil
+}
+
+// SecretsManager returns a client for the Secrets Manager service of the Localstack instance.
+func (ls *Localstack) SecretsManager() (*secretsmanager.SecretsManager, error) {
+	sess, err := ls.sessionFor("secretsmanager")
+	if err != nil {
+		return nil, err
+	}
+	return secretsmanager.New(sess), nil
+}
+
+// SFN returns a client for the Step Functions service of the Localstack instance.
+func (ls *Localstack) SFN() (*sfn.SFN, error) {
+	sess, err := ls.sessionFor("stepfunctions")
+	if err != nil {
+		return nil, err
+	}
+	return sfn.New(sess), nil
+}
+
+// STS returns a client for the STS service of the Localstack instance.
+func (ls *Localstack) STS() (*sts.STS, error) {
+	sess, err := ls.sessionFor("sts")
+	if err != nil {
+		return nil, err
+	}
+	return sts.New(sess), nil
+}
@@ -0,0 +1,81 @@
+package localstack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func newTestBackend() Backend {
+	return &dockertestBackend{
+		resource: &dockertest.Resource{
+			Container: &docker.Container{
+				NetworkSettings: &docker.NetworkSettings{Ports: portBindings},
+			},
+		},
+	}
+}
+
+func Test_Clients_OnlyRequestedServices(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	ls := &Localstack{
+		backend:  newTestBackend(),
+		Services: &LocalstackServiceCollection{*s3},
+	}
+
+	client, err := ls.S3()
+	if err != nil {
+		t.Fatalf("We were not expecting an error for a requested service.  Received %s", err)
+	}
+	if client == nil {
+		t.Fatal("The client should not be nil.")
+	}
+
+	if _, err := ls.SQS(); !errors.Is(err, ErrServiceNotRequested) {
+		t.Errorf("We were expecting ErrServiceNotRequested.  Received %v", err)
+	}
+	if _, err := ls.SFN(); !errors.Is(err, ErrServiceNotRequested) {
+		t.Errorf("We were expecting ErrServiceNotRequested.  Received %v", err)
+	}
+}
+
+func Test_Session_Cached(t *testing.T) {
+	ls := &Localstack{backend: newTestBackend(), Services: &LocalstackServiceCollection{}}
+
+	if testSession(t, ls) != testSession(t, ls) {
+		t.Error("The same session should be returned each time.")
+	}
+}
+
+func Test_Session_Error(t *testing.T) {
+	os.Setenv("AWS_PROFILE", "production")
+	defer os.Unsetenv("AWS_PROFILE")
+
+	s3, _ := NewLocalstackService("s3")
+	ls := &Localstack{backend: newTestBackend(), Services: &LocalstackServiceCollection{*s3}, strict: true}
+
+	if _, err := ls.S3(); err == nil {
+		t.Error("We were expecting the session error to be returned rather than a panic.")
+	}
+	path := writeFixtures(t, "fixtures.yml", "s3: []\n")
+	defer os.RemoveAll(filepath.Dir(path))
+	if _, err := ls.ApplyFixtures(path); err == nil {
+		t.Error("We were expecting the session error to be returned by ApplyFixtures.")
+	}
+}
+
+// testSession returns the session of the instance, failing the test when it
+// can't be created.
+func testSession(t *testing.T, ls *Localstack) *session.Session {
+	t.Helper()
+	sess, err := ls.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
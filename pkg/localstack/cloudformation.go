@@ -0,0 +1,193 @@
+package localstack
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// stackPollInterval is how often the status of a stack is checked while
+// waiting for it to be created or deleted.
+const stackPollInterval = time.Second
+
+// StackError is returned by DeployStack when a stack fails to be created.
+type StackError struct {
+	// Name is the name of the stack.
+	Name string
+	// Status is the final status of the stack. (I.E. "ROLLBACK_COMPLETE")
+	Status string
+	// Reason is the status reason reported for the stack.
+	Reason string
+	// FailedResources are the resources of the stack that failed.
+	FailedResources []*cloudformation.StackResource
+	// Events are the events of the stack, newest first.
+	Events []*cloudformation.StackEvent
+}
+
+// Error lists the failed resources and the events of the stack.
+func (err *StackError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "stack %s failed with status %s", err.Name, err.Status)
+	if err.Reason != "" {
+		fmt.Fprintf(&b, ": %s", err.Reason)
+	}
+	for _, resource := range err.FailedResources {
+		fmt.Fprintf(&b, "\n  resource %s (%s): %s %s",
+			aws.StringValue(resource.LogicalResourceId),
+			aws.StringValue(resource.ResourceType),
+			aws.StringValue(resource.ResourceStatus),
+			aws.StringValue(resource.ResourceStatusReason))
+	}
+	for _, event := range err.Events {
+		fmt.Fprintf(&b, "\n  event %s %s: %s %s",
+			aws.TimeValue(event.Timestamp).Format(time.RFC3339),
+			aws.StringValue(event.LogicalResourceId),
+			aws.StringValue(event.ResourceStatus),
+			aws.StringValue(event.ResourceStatusReason))
+	}
+	return b.String()
+}
+
+// DeployStack creates a CloudFormation stack from the template at the given path
+// and waits for it to reach CREATE_COMPLETE.  The outputs of the stack are
+// returned as a map of output key to value.  A matching DeleteStack is
+// registered to be run when the instance is destroyed.  When the stack fails,
+// the error is a *StackError describing the failed resources and stack events.
+func (ls *Localstack) DeployStack(ctx context.Context, name, templatePath string,
+	params map[string]string) (map[string]string, error) {
+	template, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read template %s: %s", templatePath, err)
+	}
+
+	input := &cloudformation.CreateStackInput{
+		StackName:    aws.String(name),
+		TemplateBody: aws.String(string(template)),
+		Capabilities: aws.StringSlice([]string{
+			cloudformation.CapabilityCapabilityIam,
+			cloudformation.CapabilityCapabilityNamedIam,
+			cloudformation.CapabilityCapabilityAutoExpand,
+		}),
+	}
+	for key, value := range params {
+		input.Parameters = append(input.Parameters, &cloudformation.Parameter{
+			ParameterKey:   aws.String(key),
+			ParameterValue: aws.String(value),
+		})
+	}
+
+	sess, err := ls.Session()
+	if err != nil {
+		return nil, err
+	}
+	svc := cloudformation.New(sess)
+	if _, err := svc.CreateStackWithContext(ctx, input); err != nil {
+		return nil, fmt.Errorf("unable to create stack %s: %s", name, err)
+	}
+	ls.addCleanup(func() error {
+		return ls.DeleteStack(context.Background(), name)
+	})
+
+	stack, err := waitForStack(ctx, svc, name)
+	if err != nil {
+		return nil, err
+	}
+	if stack == nil {
+		return nil, fmt.Errorf("stack %s no longer exists", name)
+	}
+
+	if aws.StringValue(stack.StackStatus) != cloudformation.StackStatusCreateComplete {
+		return nil, describeStackFailure(ctx, svc, stack)
+	}
+
+	outputs := map[string]string{}
+	for _, output := range stack.Outputs {
+		outputs[aws.StringValue(output.OutputKey)] = aws.StringValue(output.OutputValue)
+	}
+	return outputs, nil
+}
+
+// DeleteStack deletes a CloudFormation stack and waits for the deletion to finish.
+func (ls *Localstack) DeleteStack(ctx context.Context, name string) error {
+	sess, err := ls.Session()
+	if err != nil {
+		return err
+	}
+	svc := cloudformation.New(sess)
+	if _, err := svc.DeleteStackWithContext(ctx, &cloudformation.DeleteStackInput{StackName: aws.String(name)}); err != nil {
+		return fmt.Errorf("unable to delete stack %s: %s", name, err)
+	}
+
+	stack, err := waitForStack(ctx, svc, name)
+	if err != nil {
+		return err
+	}
+	if stack != nil && aws.StringValue(stack.StackStatus) != cloudformation.StackStatusDeleteComplete {
+		return fmt.Errorf("unable to delete stack %s: %s", name, aws.StringValue(stack.StackStatus))
+	}
+	return nil
+}
+
+// waitForStack polls the named stack until it is no longer in progress.  nil is
+// returned when the stack no longer exists.
+func waitForStack(ctx context.Context, svc *cloudformation.CloudFormation, name string) (*cloudformation.Stack, error) {
+	for {
+		output, err := svc.DescribeStacksWithContext(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(name)})
+		if err != nil {
+			// CloudFormation reports a missing stack as a validation error.
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ValidationError" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unable to describe stack %s: %s", name, err)
+		}
+		if len(output.Stacks) == 0 {
+			return nil, nil
+		}
+
+		stack := output.Stacks[0]
+		if !strings.HasSuffix(aws.StringValue(stack.StackStatus), "_IN_PROGRESS") {
+			return stack, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stack %s is still %s: %s", name, aws.StringValue(stack.StackStatus), ctx.Err())
+		case <-time.After(stackPollInterval):
+		}
+	}
+}
+
+// describeStackFailure gathers the failed resources and events of a stack.
+func describeStackFailure(ctx context.Context, svc *cloudformation.CloudFormation, stack *cloudformation.Stack) error {
+	stackErr := &StackError{
+		Name:   aws.StringValue(stack.StackName),
+		Status: aws.StringValue(stack.StackStatus),
+		Reason: aws.StringValue(stack.StackStatusReason),
+	}
+
+	resources, err := svc.DescribeStackResourcesWithContext(ctx, &cloudformation.DescribeStackResourcesInput{
+		StackName: stack.StackName,
+	})
+	if err == nil {
+		for _, resource := range resources.StackResources {
+			if strings.HasSuffix(aws.StringValue(resource.ResourceStatus), "_FAILED") {
+				stackErr.FailedResources = append(stackErr.FailedResources, resource)
+			}
+		}
+	}
+
+	//nolint:errcheck
+	svc.DescribeStackEventsPagesWithContext(ctx, &cloudformation.DescribeStackEventsInput{StackName: stack.StackName},
+		func(page *cloudformation.DescribeStackEventsOutput, _ bool) bool {
+			stackErr.Events = append(stackErr.Events, page.StackEvents...)
+			return true
+		})
+
+	return stackErr
+}
@@ -0,0 +1,245 @@
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func Test_StackError_Error(t *testing.T) {
+	err := &StackError{
+		Name:   "example",
+		Status: cloudformation.StackStatusRollbackComplete,
+		Reason: "The following resource(s) failed to create: [Bucket].",
+		FailedResources: []*cloudformation.StackResource{
+			{
+				LogicalResourceId:    aws.String("Bucket"),
+				ResourceType:         aws.String("AWS::S3::Bucket"),
+				ResourceStatus:       aws.String(cloudformation.ResourceStatusCreateFailed),
+				ResourceStatusReason: aws.String("Invalid bucket name"),
+			},
+		},
+		Events: []*cloudformation.StackEvent{
+			{
+				Timestamp:            aws.Time(time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)),
+				LogicalResourceId:    aws.String("Bucket"),
+				ResourceStatus:       aws.String(cloudformation.ResourceStatusCreateFailed),
+				ResourceStatusReason: aws.String("Invalid bucket name"),
+			},
+		},
+	}
+
+	message := err.Error()
+	expected := []string{
+		"stack example failed with status ROLLBACK_COMPLETE: The following resource(s) failed to create: [Bucket].",
+		"resource Bucket (AWS::S3::Bucket): CREATE_FAILED Invalid bucket name",
+		"event 2020-09-01T12:00:00Z Bucket: CREATE_FAILED Invalid bucket name",
+	}
+	for _, e := range expected {
+		if !strings.Contains(message, e) {
+			t.Errorf("The error message should contain %q.  Received %s", e, message)
+		}
+	}
+}
+
+// fakeCloudFormation serves the CloudFormation calls made by DeployStack and
+// DeleteStack for a single stack.
+type fakeCloudFormation struct {
+	// status is the status the stack is created with.
+	status string
+	// slow reports the stack in progress the first time it is described.
+	slow bool
+	// deleteStatus is the status the stack is left in once deleted.  When
+	// empty, the stack no longer exists.
+	deleteStatus string
+
+	mu         sync.Mutex
+	created    bool
+	deleted    bool
+	described  int
+	parameters map[string]string
+	actions    []string
+}
+
+type fakeStack struct {
+	StackName         string
+	StackId           string //nolint:golint
+	StackStatus       string
+	StackStatusReason string
+	Outputs           []fakeStackOutput `xml:"Outputs>member"`
+}
+
+type fakeStackOutput struct {
+	OutputKey   string
+	OutputValue string
+}
+
+type fakeStackResource struct {
+	LogicalResourceId    string //nolint:golint
+	ResourceType         string
+	ResourceStatus       string
+	ResourceStatusReason string
+}
+
+type fakeStackEvent struct {
+	Timestamp         string
+	LogicalResourceId string //nolint:golint
+	ResourceStatus    string
+}
+
+func (f *fakeCloudFormation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	call := describeCall(r, body)
+	values, _ := url.ParseQuery(string(body))
+	name := values.Get("StackName")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	action := values.Get("Action")
+	f.actions = append(f.actions, action)
+
+	stack := fakeStack{StackName: name, StackId: "arn:aws:cloudformation:us-east-1:000000000000:stack/" + name}
+	switch action {
+	case "CreateStack":
+		f.created = true
+		f.parameters = map[string]string{}
+		for i := 1; values.Get(fmt.Sprintf("Parameters.member.%d.ParameterKey", i)) != ""; i++ {
+			f.parameters[values.Get(fmt.Sprintf("Parameters.member.%d.ParameterKey", i))] =
+				values.Get(fmt.Sprintf("Parameters.member.%d.ParameterValue", i))
+		}
+		writeSQSResult(w, action, struct {
+			StackId string //nolint:golint
+		}{stack.StackId})
+	case "DeleteStack":
+		f.deleted = true
+		writeSQSResult(w, action, nil)
+	case "DescribeStacks":
+		if !f.created || (f.deleted && f.deleteStatus == "") {
+			writeAWSError(w, &call, http.StatusBadRequest, "ValidationError",
+				fmt.Sprintf("Stack with id %s does not exist", name))
+			return
+		}
+		f.described++
+		switch {
+		case f.deleted:
+			stack.StackStatus = f.deleteStatus
+		case f.slow && f.described == 1:
+			stack.StackStatus = cloudformation.StackStatusCreateInProgress
+		default:
+			stack.StackStatus = f.status
+			stack.Outputs = []fakeStackOutput{{"BucketName", "orders-" + f.parameters["Environment"]}}
+		}
+		if f.status == cloudformation.StackStatusRollbackComplete {
+			stack.StackStatusReason = "The following resource(s) failed to create: [Bucket]."
+		}
+		writeSQSResult(w, action, struct {
+			Stacks []fakeStack `xml:"Stacks>member"`
+		}{[]fakeStack{stack}})
+	case "DescribeStackResources":
+		writeSQSResult(w, action, struct {
+			StackResources []fakeStackResource `xml:"StackResources>member"`
+		}{[]fakeStackResource{
+			{"Bucket", "AWS::S3::Bucket", cloudformation.ResourceStatusCreateFailed, "Invalid bucket name"},
+			{"Queue", "AWS::SQS::Queue", cloudformation.ResourceStatusCreateComplete, ""},
+		}})
+	case "DescribeStackEvents":
+		writeSQSResult(w, action, struct {
+			StackEvents []fakeStackEvent `xml:"StackEvents>member"`
+		}{[]fakeStackEvent{{"2020-09-01T12:00:00Z", "Bucket", cloudformation.ResourceStatusCreateFailed}}})
+	default:
+		unsupportedOperation(w, &call)
+	}
+}
+
+// newStackLocalstack returns an instance whose CloudFormation calls are
+// served by fake, and the path of a template to deploy.
+func newStackLocalstack(t *testing.T, fake *fakeCloudFormation) (*Localstack, string) {
+	stacks, _ := NewLocalstackService("cloudformation")
+	ls, err := NewLocalstack(&LocalstackServiceCollection{*stacks}, WithBackend(&FakeBackend{Handler: fake}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ls, writeFixtures(t, "template.yml", "Resources: {}\n")
+}
+
+func Test_DeployStack_Created(t *testing.T) {
+	fake := &fakeCloudFormation{status: cloudformation.StackStatusCreateComplete, slow: true}
+	ls, template := newStackLocalstack(t, fake)
+	defer os.RemoveAll(filepath.Dir(template))
+
+	outputs, err := ls.DeployStack(context.Background(), "orders", template, map[string]string{"Environment": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs["BucketName"] != "orders-test" {
+		t.Errorf("We were expecting the outputs of the stack.  Received %v", outputs)
+	}
+	fake.mu.Lock()
+	described := fake.described
+	fake.mu.Unlock()
+	if described != 2 {
+		t.Errorf("We were expecting the stack to be waited for.  Received %d describes", described)
+	}
+
+	// The stack is deleted, and waited for, when the instance is destroyed.
+	if err := ls.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if !fake.deleted {
+		t.Errorf("We were expecting the stack to be deleted on destroy.  Received %v", fake.actions)
+	}
+}
+
+func Test_DeployStack_Rollback(t *testing.T) {
+	fake := &fakeCloudFormation{status: cloudformation.StackStatusRollbackComplete}
+	ls, template := newStackLocalstack(t, fake)
+	defer os.RemoveAll(filepath.Dir(template))
+	defer ls.Destroy()
+
+	_, err := ls.DeployStack(context.Background(), "orders", template, nil)
+	var stackErr *StackError
+	if !errors.As(err, &stackErr) {
+		t.Fatalf("We were expecting a StackError.  Received %v", err)
+	}
+	if stackErr.Status != cloudformation.StackStatusRollbackComplete || stackErr.Reason == "" {
+		t.Errorf("We were expecting the status of the stack.  Received %+v", stackErr)
+	}
+	if len(stackErr.FailedResources) != 1 || aws.StringValue(stackErr.FailedResources[0].LogicalResourceId) != "Bucket" {
+		t.Errorf("We were expecting only the failed resource.  Received %v", stackErr.FailedResources)
+	}
+	if len(stackErr.Events) != 1 || aws.StringValue(stackErr.Events[0].ResourceStatus) != cloudformation.ResourceStatusCreateFailed {
+		t.Errorf("We were expecting the events of the stack.  Received %v", stackErr.Events)
+	}
+}
+
+func Test_DeleteStack_Failed(t *testing.T) {
+	fake := &fakeCloudFormation{status: cloudformation.StackStatusCreateComplete,
+		deleteStatus: cloudformation.StackStatusDeleteFailed}
+	ls, template := newStackLocalstack(t, fake)
+	defer os.RemoveAll(filepath.Dir(template))
+
+	if _, err := ls.DeployStack(context.Background(), "orders", template, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := ls.DeleteStack(context.Background(), "orders")
+	if err == nil || !strings.Contains(err.Error(), cloudformation.StackStatusDeleteFailed) {
+		t.Errorf("We were expecting the failed deletion to be reported.  Received %v", err)
+	}
+	if err := ls.Destroy(); err == nil {
+		t.Error("We were expecting the registered cleanup to report the failed deletion.")
+	}
+}
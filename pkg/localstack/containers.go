@@ -0,0 +1,219 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+// ManagedLabel is the label set on every container created by this package.
+const ManagedLabel = "go_localstack"
+
+// ServicesLabel is the label holding the services a container was started for.
+const ServicesLabel = "go_localstack.services"
+
+// NameLabel is the label holding the name a container was given, if any.
+// Named containers are long-lived and reused, so they are only pruned when
+// asked for.
+const NameLabel = "go_localstack.name"
+
+// TestLabel is the label holding the name of the test a container was
+// started for by NewLocalstackForTest.
+const TestLabel = "go_localstack.test"
+
+// KeptNamePrefix starts the name of the containers kept because their test
+// failed.  Like named containers, they are only pruned when asked for.
+const KeptNamePrefix = "go_localstack-kept-"
+
+// pruneRunningAfter is how long a running container is left alone by
+// PruneContainers, as a test or pool may still be using it.
+const pruneRunningAfter = time.Hour
+
+// ErrContainerNotFound is returned when no container managed by this package
+// has the requested name.
+var ErrContainerNotFound = errors.New("localstack container not found")
+
+// ManagedContainer is a Localstack container created by this package.
+type ManagedContainer struct {
+	// ID is the container ID.
+	ID string
+	// Name is the container name.
+	Name string
+	// Image is the image the container was created from.
+	Image string
+	// Services are the services the container was started for.
+	Services []string
+	// Endpoint is the URL of the edge port, or empty when it isn't published.
+	Endpoint string
+	// Status is the status reported by Docker, e.g. "Up 5 minutes".
+	Status string
+	// Running is true while the container is running.
+	Running bool
+	// Created is when the container was created.
+	Created time.Time
+	// Named is true when the container was given a name when started.
+	Named bool
+	// Test is the name of the test the container was started for, if any.
+	Test string
+	// Kept is true when the container was kept because its test failed.
+	Kept bool
+
+	wrapper DockerWrapper
+}
+
+// containerLabels returns the labels set on a container started for the given
+// services.
+func containerLabels(services *LocalstackServiceCollection, name string) map[string]string {
+	names := make([]string, 0, len(*services))
+	for _, service := range *services {
+		names = append(names, service.Name)
+	}
+	labels := map[string]string{
+		ManagedLabel:  "true",
+		ServicesLabel: strings.Join(names, ","),
+	}
+	if name != "" {
+		labels[NameLabel] = name
+	}
+	return labels
+}
+
+// ListContainers returns the containers created by this package, sorted by
+// name.
+func ListContainers() ([]*ManagedContainer, error) {
+	return listContainers(&_DockerWrapper{})
+}
+
+func listContainers(wrapper DockerWrapper) ([]*ManagedContainer, error) {
+	containers, err := wrapper.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": {ManagedLabel + "=true"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve docker containers: %s", err)
+	}
+
+	managed := make([]*ManagedContainer, 0, len(containers))
+	for i := range containers {
+		managed = append(managed, newManagedContainer(wrapper, &containers[i]))
+	}
+	sort.Slice(managed, func(i, j int) bool {
+		return managed[i].Name < managed[j].Name
+	})
+	return managed, nil
+}
+
+// newManagedContainer describes a container listed by Docker.
+func newManagedContainer(wrapper DockerWrapper, c *docker.APIContainers) *ManagedContainer {
+	container := &ManagedContainer{
+		ID:      c.ID,
+		Image:   c.Image,
+		Status:  c.Status,
+		Running: c.State == "running",
+		Created: time.Unix(c.Created, 0),
+		wrapper: wrapper,
+	}
+	if len(c.Names) > 0 {
+		container.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	if services := c.Labels[ServicesLabel]; services != "" {
+		container.Services = strings.Split(services, ",")
+	}
+	_, container.Named = c.Labels[NameLabel]
+	container.Kept = strings.HasPrefix(container.Name, KeptNamePrefix)
+	container.Test = c.Labels[TestLabel]
+	for _, port := range c.Ports {
+		if port.PrivatePort == 4566 && port.PublicPort != 0 {
+			ip := port.IP
+			if ip == "" || ip == "0.0.0.0" || ip == "::" {
+				ip = "localhost"
+			}
+			container.Endpoint = fmt.Sprintf("http://%s", net.JoinHostPort(ip, strconv.FormatInt(port.PublicPort, 10)))
+			break
+		}
+	}
+	return container
+}
+
+// FindContainer returns the container created by this package with the given
+// name, or ErrContainerNotFound.
+func FindContainer(name string) (*ManagedContainer, error) {
+	return findContainer(&_DockerWrapper{}, name)
+}
+
+func findContainer(wrapper DockerWrapper, name string) (*ManagedContainer, error) {
+	containers, err := listContainers(wrapper)
+	if err != nil {
+		return nil, err
+	}
+	for _, container := range containers {
+		if container.Name == name {
+			return container, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrContainerNotFound)
+}
+
+// PruneContainers removes the containers created by this package and returns
+// them.  Named containers, those kept for a failed test and those started in
+// the last hour that are still running, as a test may be using them, are left
+// alone unless all is true.
+func PruneContainers(all bool) ([]*ManagedContainer, error) {
+	return pruneContainers(&_DockerWrapper{}, all)
+}
+
+func pruneContainers(wrapper DockerWrapper, all bool) ([]*ManagedContainer, error) {
+	containers, err := listContainers(wrapper)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []*ManagedContainer
+	for _, container := range containers {
+		inUse := container.Running && time.Since(container.Created) < pruneRunningAfter
+		if (container.Named || container.Kept || inUse) && !all {
+			continue
+		}
+		if err := container.Remove(); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, container)
+	}
+	return pruned, nil
+}
+
+// Logs writes the output of the container so far to w.
+func (c *ManagedContainer) Logs(w io.Writer) error {
+	err := c.wrapper.Logs(docker.LogsOptions{
+		Container:    c.ID,
+		OutputStream: w,
+		ErrorStream:  w,
+		Stdout:       true,
+		Stderr:       true,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve logs for container %s: %s", c.ID, err)
+	}
+	return nil
+}
+
+// Remove removes the container and its volumes, stopping it if needed.
+func (c *ManagedContainer) Remove() error {
+	if err := c.wrapper.Purge(&dockertest.Resource{Container: &docker.Container{ID: c.ID}}); err != nil {
+		return fmt.Errorf("could not remove container %s: %s", c.Name, err)
+	}
+	return nil
+}
+
+// Env returns the environment variables that point the AWS CLI and SDKs, and
+// the tests of this package, at the container.
+func (c *ManagedContainer) Env() map[string]string {
+	return awsEnv(c.Endpoint, c.Services)
+}
@@ -0,0 +1,182 @@
+package localstack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nichobbs/go_localstack/pkg/mock_localstack"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_ListContainers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		ListContainers(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+			if opts.Filters["label"][0] != "go_localstack=true" {
+				t.Errorf("Only managed containers should be listed.  Received %v", opts.Filters)
+			}
+			return []docker.APIContainers{{
+				ID:     "2",
+				Names:  []string{"/dev"},
+				State:  "running",
+				Labels: map[string]string{ManagedLabel: "true", ServicesLabel: "s3,sqs", NameLabel: "dev"},
+				Ports:  []docker.APIPort{{PrivatePort: 4566, PublicPort: 32768, IP: "0.0.0.0"}},
+			}, {
+				ID:     "1",
+				Names:  []string{"/brave_turing"},
+				State:  "exited",
+				Labels: map[string]string{ManagedLabel: "true", ServicesLabel: "sqs"},
+			}}, nil
+		})
+
+	containers, err := listContainers(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 || containers[0].Name != "brave_turing" || containers[1].Name != "dev" {
+		t.Fatalf("We were expecting the containers sorted by name.  Received %v", containers)
+	}
+	dev := containers[1]
+	if !dev.Running || !dev.Named || len(dev.Services) != 2 || dev.Endpoint != "http://localhost:32768" {
+		t.Errorf("The named container was not described correctly.  Received %+v", dev)
+	}
+	if containers[0].Running || containers[0].Named || containers[0].Endpoint != "" {
+		t.Errorf("The test container was not described correctly.  Received %+v", containers[0])
+	}
+	if dev.Env()[EndpointEnv] != dev.Endpoint {
+		t.Errorf("The environment should point at the container.  Received %v", dev.Env())
+	}
+}
+
+func Test_PruneContainers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		ListContainers(gomock.Any()).
+		Times(2).
+		Return([]docker.APIContainers{
+			{ID: "1", Names: []string{"/dev"}, Labels: map[string]string{NameLabel: "dev"}},
+			{ID: "2", Names: []string{"/brave_turing"}},
+		}, nil)
+
+	var removed []string
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(3).
+		DoAndReturn(func(resource *dockertest.Resource) error {
+			removed = append(removed, resource.Container.ID)
+			return nil
+		})
+
+	pruned, err := pruneContainers(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || len(removed) != 1 || removed[0] != "2" {
+		t.Errorf("Only the unnamed container should be pruned.  Received %v", removed)
+	}
+
+	if pruned, err = pruneContainers(m, true); err != nil || len(pruned) != 2 {
+		t.Errorf("Every container should be pruned.  Received %v %v", pruned, err)
+	}
+}
+
+func Test_PruneContainers_Running(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		ListContainers(gomock.Any()).
+		Times(2).
+		Return([]docker.APIContainers{
+			{ID: "1", Names: []string{"/in_use"}, State: "running", Created: time.Now().Unix()},
+			{ID: "2", Names: []string{"/leaked"}, State: "running", Created: time.Now().Add(-2 * time.Hour).Unix()},
+			{ID: "3", Names: []string{"/stopped"}, State: "exited", Created: time.Now().Unix()},
+		}, nil)
+
+	var removed []string
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(5).
+		DoAndReturn(func(resource *dockertest.Resource) error {
+			removed = append(removed, resource.Container.ID)
+			return nil
+		})
+
+	if _, err := pruneContainers(m, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0] != "2" || removed[1] != "3" {
+		t.Errorf("A recently started running container may be in use and should not be pruned.  Received %v", removed)
+	}
+
+	if pruned, err := pruneContainers(m, true); err != nil || len(pruned) != 3 {
+		t.Errorf("Every container should be pruned.  Received %v %v", pruned, err)
+	}
+}
+
+func Test_FindContainer_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		ListContainers(gomock.Any()).
+		Times(1).
+		Return(nil, nil)
+
+	if _, err := findContainer(m, "dev"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("We were expecting ErrContainerNotFound.  Received %v", err)
+	}
+}
+
+func Test_PruneContainers_Kept(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		ListContainers(gomock.Any()).
+		Times(1).
+		Return([]docker.APIContainers{
+			{ID: "1", Names: []string{"/" + KeptNamePrefix + "testorders-1"}, Labels: map[string]string{TestLabel: "TestOrders"}},
+			{ID: "2", Names: []string{"/leaked"}, Labels: map[string]string{TestLabel: "TestPayments"}},
+		}, nil)
+
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	pruned, err := pruneContainers(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].Name != "leaked" || pruned[0].Test != "TestPayments" {
+		t.Errorf("Only the container that wasn't kept should be pruned.  Received %v", pruned)
+	}
+}
@@ -0,0 +1,180 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/ory/dockertest/docker"
+)
+
+// anonymousVolume matches the names Docker gives anonymous volumes.
+var anonymousVolume = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// DestroyOption configures how DestroyWithOptions stops the instance.
+type DestroyOption func(*destroyOptions)
+
+// destroyOptions holds the configuration gathered from DestroyOption values.
+type destroyOptions struct {
+	// stopTimeout is how long the container is given to stop before it is
+	// killed.
+	stopTimeout time.Duration
+	// keep leaves the container in place.
+	keep bool
+	// logs receives the output of the container before it is removed.
+	logs io.Writer
+	// removeAnonymousVolumes and removeNamedVolumes choose which volumes of
+	// the container are removed with it.
+	removeAnonymousVolumes bool
+	removeNamedVolumes     bool
+}
+
+// WithStopTimeout stops the container gracefully, giving Localstack up to
+// timeout to flush persisted data and exit before it is killed.  Without
+// it, the container is killed right away.
+func WithStopTimeout(timeout time.Duration) DestroyOption {
+	return func(o *destroyOptions) {
+		o.stopTimeout = timeout
+	}
+}
+
+// WithKeepContainer leaves the container in place for debugging when keep is
+// true, e.g. WithKeepContainer(t.Failed()).  It keeps running unless a stop
+// timeout is set too.  The cleanups registered against the instance still
+// run.
+func WithKeepContainer(keep bool) DestroyOption {
+	return func(o *destroyOptions) {
+		o.keep = keep
+	}
+}
+
+// WithLogCapture writes the output of the container to w before it is
+// removed.  With WithStopTimeout the output of the graceful stop is included,
+// unless Docker removes the container as soon as it stops (WithAutoRemove).
+func WithLogCapture(w io.Writer) DestroyOption {
+	return func(o *destroyOptions) {
+		o.logs = w
+	}
+}
+
+// WithVolumeRemoval chooses whether the anonymous and named volumes of the
+// container are removed with it.  By default anonymous volumes are removed,
+// as they are by Destroy, and named volumes are kept.
+func WithVolumeRemoval(anonymous, named bool) DestroyOption {
+	return func(o *destroyOptions) {
+		o.removeAnonymousVolumes = anonymous
+		o.removeNamedVolumes = named
+	}
+}
+
+// DestroyWithOptions is Destroy with control over how the container is
+// stopped and what is left behind.
+func (ls *Localstack) DestroyWithOptions(opts ...DestroyOption) error {
+	o := &destroyOptions{removeAnonymousVolumes: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return ls.destroy(o)
+}
+
+// gracefulStopper is implemented by the backends that can stop gracefully.
+type gracefulStopper interface {
+	stopWith(o *destroyOptions) error
+}
+
+// stopBackend stops the backend as configured.  Backends that can't stop
+// gracefully have their logs captured, when they have any, and are stopped
+// unless kept.
+func stopBackend(backend Backend, o *destroyOptions) error {
+	if stopper, ok := backend.(gracefulStopper); ok {
+		return stopper.stopWith(o)
+	}
+	if o.logs != nil {
+		if err := backend.Logs(o.logs); err != nil && !errors.Is(err, ErrNotSupported) {
+			return err
+		}
+	}
+	if o.keep {
+		return nil
+	}
+	return backend.Stop()
+}
+
+// stopWith stops the container gracefully, captures the logs and removes it
+// with the chosen volumes.  A container already gone, e.g. removed by Docker
+// on stop with WithAutoRemove, counts as removed.
+func (b *dockertestBackend) stopWith(o *destroyOptions) error {
+	id := resourceID(b.container())
+
+	// The volumes and whether Docker removes the container once it stops are
+	// only known while the container exists.
+	var container *docker.Container
+	if (o.removeNamedVolumes && !o.keep) || (o.logs != nil && o.stopTimeout > 0) {
+		var err error
+		if container, err = b.wrapper.InspectContainer(id); err != nil {
+			return fmt.Errorf("unable to inspect container %s: %s", id, err)
+		}
+	}
+	autoRemoved := container != nil && container.HostConfig != nil && container.HostConfig.AutoRemove
+
+	// The logs are captured after a graceful stop, so they include its output,
+	// unless the stop removes the container.
+	logsAfterStop := o.stopTimeout > 0 && !autoRemoved
+	if o.logs != nil && !logsAfterStop {
+		if err := b.Logs(o.logs); err != nil {
+			return err
+		}
+	}
+
+	var named []string
+	if o.removeNamedVolumes && !o.keep {
+		for _, mount := range container.Mounts {
+			if mount.Name != "" && !anonymousVolume.MatchString(mount.Name) {
+				named = append(named, mount.Name)
+			}
+		}
+	}
+
+	var noSuchContainer *docker.NoSuchContainer
+	if o.stopTimeout > 0 {
+		// Docker counts the timeout in whole seconds.
+		seconds := uint((o.stopTimeout + time.Second - 1) / time.Second)
+		var notRunning *docker.ContainerNotRunning
+		err := b.wrapper.StopContainer(id, seconds)
+		if err != nil && !errors.As(err, &notRunning) && !errors.As(err, &noSuchContainer) {
+			return fmt.Errorf("could not stop container %s: %s", id, err)
+		}
+	}
+	if o.logs != nil && logsAfterStop {
+		if err := b.Logs(o.logs); err != nil {
+			return err
+		}
+	}
+	if o.keep {
+		return nil
+	}
+
+	err := b.wrapper.RemoveContainer(docker.RemoveContainerOptions{
+		ID:            id,
+		Force:         true,
+		RemoveVolumes: o.removeAnonymousVolumes,
+	})
+	if err != nil && !errors.As(err, &noSuchContainer) {
+		return fmt.Errorf("could not remove container %s: %s", id, err)
+	}
+	for _, name := range named {
+		if err := b.wrapper.RemoveVolume(name); err != nil {
+			return fmt.Errorf("could not remove volume %s: %s", name, err)
+		}
+	}
+
+	if b.initScriptDir != "" {
+		if err := os.RemoveAll(b.initScriptDir); err != nil {
+			return fmt.Errorf("could not remove init scripts: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package localstack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nichobbs/go_localstack/pkg/mock_localstack"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_DestroyWithOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{*sqs}
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	ls := &Localstack{
+		Services: services,
+		backend: &dockertestBackend{
+			wrapper:  m,
+			resource: &dockertest.Resource{Container: &docker.Container{ID: "container"}},
+		},
+	}
+	anonymous := strings.Repeat("a", 64)
+
+	// The logs are captured once stopped, so they include the shutdown.
+	stopped := false
+	m.
+		EXPECT().
+		Logs(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts docker.LogsOptions) error {
+			if !stopped {
+				t.Error("The logs should be captured after the container is stopped.")
+			}
+			opts.OutputStream.Write([]byte("Ready."))
+			return nil
+		})
+	m.
+		EXPECT().
+		StopContainer("container", uint(2)).
+		Times(1).
+		DoAndReturn(func(string, uint) error {
+			stopped = true
+			return &docker.ContainerNotRunning{ID: "container"}
+		})
+	m.
+		EXPECT().
+		InspectContainer("container").
+		Times(1).
+		Return(&docker.Container{Mounts: []docker.Mount{{Name: anonymous}, {Name: "data"}, {Source: "/tmp"}}}, nil)
+	m.
+		EXPECT().
+		RemoveContainer(docker.RemoveContainerOptions{ID: "container", Force: true, RemoveVolumes: false}).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		RemoveVolume("data").
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(0)
+
+	logs := new(bytes.Buffer)
+	err := ls.DestroyWithOptions(
+		WithStopTimeout(1500*time.Millisecond),
+		WithLogCapture(logs),
+		WithVolumeRemoval(false, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs.String() != "Ready." {
+		t.Errorf("We were expecting the logs to be captured.  Received %q", logs)
+	}
+}
+
+func Test_DestroyWithOptions_AutoRemoved(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	ls := &Localstack{
+		backend: &dockertestBackend{
+			wrapper:  m,
+			resource: &dockertest.Resource{Container: &docker.Container{ID: "container"}},
+		},
+	}
+
+	// Docker removes the container once it is stopped.
+	m.
+		EXPECT().
+		StopContainer("container", uint(5)).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		RemoveContainer(gomock.Any()).
+		Times(1).
+		Return(&docker.NoSuchContainer{ID: "container"})
+
+	if err := ls.DestroyWithOptions(WithStopTimeout(5 * time.Second)); err != nil {
+		t.Errorf("We were expecting an auto-removed container to count as removed.  Received %v", err)
+	}
+}
+
+func Test_DestroyWithOptions_AutoRemovedLogs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	ls := &Localstack{
+		backend: &dockertestBackend{
+			wrapper:  m,
+			resource: &dockertest.Resource{Container: &docker.Container{ID: "container"}},
+		},
+	}
+
+	// Docker removes the container once it stops, so the logs are captured
+	// before.
+	stopped := false
+	m.
+		EXPECT().
+		InspectContainer("container").
+		Times(1).
+		Return(&docker.Container{HostConfig: &docker.HostConfig{AutoRemove: true}}, nil)
+	m.
+		EXPECT().
+		Logs(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts docker.LogsOptions) error {
+			if stopped {
+				t.Error("The logs of an auto-removed container should be captured before it is stopped.")
+			}
+			opts.OutputStream.Write([]byte("Ready."))
+			return nil
+		})
+	m.
+		EXPECT().
+		StopContainer("container", uint(5)).
+		Times(1).
+		DoAndReturn(func(string, uint) error {
+			stopped = true
+			return nil
+		})
+	m.
+		EXPECT().
+		RemoveContainer(gomock.Any()).
+		Times(1).
+		Return(&docker.NoSuchContainer{ID: "container"})
+
+	logs := new(bytes.Buffer)
+	if err := ls.DestroyWithOptions(WithStopTimeout(5*time.Second), WithLogCapture(logs)); err != nil {
+		t.Fatal(err)
+	}
+	if logs.String() != "Ready." {
+		t.Errorf("We were expecting the logs to be captured.  Received %q", logs)
+	}
+}
+
+func Test_DestroyWithOptions_KeepContainer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	ls := &Localstack{
+		backend: &dockertestBackend{
+			wrapper:  m,
+			resource: &dockertest.Resource{Container: &docker.Container{ID: "container"}},
+		},
+	}
+
+	m.
+		EXPECT().
+		StopContainer("container", uint(10)).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		RemoveContainer(gomock.Any()).
+		Times(0)
+
+	cleaned := false
+	ls.addCleanup(func() error {
+		cleaned = true
+		return nil
+	})
+	if err := ls.DestroyWithOptions(WithStopTimeout(10*time.Second), WithKeepContainer(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !cleaned {
+		t.Error("The cleanups should run when the container is kept.")
+	}
+}
+
+func Test_DestroyWithOptions_OtherBackends(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	fake := &FakeBackend{Output: "Ready."}
+
+	ls, err := NewLocalstack(services, WithBackend(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := new(bytes.Buffer)
+	if err := ls.DestroyWithOptions(WithLogCapture(logs), WithKeepContainer(true)); err != nil {
+		t.Fatal(err)
+	}
+	if logs.String() != "Ready." || fake.Stopped() {
+		t.Errorf("We were expecting the logs and the backend to be kept.  Received %q", logs)
+	}
+	if err := ls.DestroyWithOptions(WithLogCapture(new(bytes.Buffer))); err != nil || !fake.Stopped() {
+		t.Errorf("We were expecting the backend to be stopped.  Received %v", err)
+	}
+
+	external := &Localstack{backend: NewExternalBackend("http://localhost:1")}
+	if err := external.DestroyWithOptions(WithLogCapture(new(bytes.Buffer))); err != nil {
+		t.Errorf("Backends without logs should still be destroyed.  Received %v", err)
+	}
+}
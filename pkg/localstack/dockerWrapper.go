@@ -20,6 +20,28 @@ type DockerWrapper interface {
 	RunWithOptions(*dockertest.RunOptions, ...func(*docker.HostConfig)) (*dockertest.Resource, error)
 	// See https://godoc.org/github.com/ory/dockertest/docker#Client.Retry
 	Retry(func() error) error
+	// See https://godoc.org/github.com/ory/dockertest#Pool.Purge
+	Purge(*dockertest.Resource) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.Logs
+	Logs(docker.LogsOptions) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.CreateExec
+	CreateExec(docker.CreateExecOptions) (*docker.Exec, error)
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.StartExec
+	StartExec(string, docker.StartExecOptions) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.InspectExec
+	InspectExec(string) (*docker.ExecInspect, error)
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.InspectImage
+	InspectImage(string) (*docker.Image, error)
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.PullImage
+	PullImage(docker.PullImageOptions, docker.AuthConfiguration) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.StopContainer
+	StopContainer(string, uint) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.RemoveContainer
+	RemoveContainer(docker.RemoveContainerOptions) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.RemoveVolume
+	RemoveVolume(string) error
+	// See https://godoc.org/github.com/ory/dockertest/docker#Client.RenameContainer
+	RenameContainer(docker.RenameContainerOptions) error
 }
 
 type _DockerWrapper struct{}
@@ -57,3 +79,91 @@ func (dw *_DockerWrapper) Retry(op func() error) error {
 	pool.MaxWait = time.Minute * 5
 	return pool.Retry(op)
 }
+
+func (dw *_DockerWrapper) Purge(resource *dockertest.Resource) error {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return fmt.Errorf("could not connect to docker: %s", err)
+	}
+	return pool.Purge(resource)
+}
+
+func (dw *_DockerWrapper) Logs(options docker.LogsOptions) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.Logs(options)
+}
+
+func (dw *_DockerWrapper) CreateExec(options docker.CreateExecOptions) (*docker.Exec, error) {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.CreateExec(options)
+}
+
+func (dw *_DockerWrapper) StartExec(id string, options docker.StartExecOptions) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.StartExec(id, options)
+}
+
+func (dw *_DockerWrapper) InspectExec(id string) (*docker.ExecInspect, error) {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.InspectExec(id)
+}
+
+func (dw *_DockerWrapper) InspectImage(name string) (*docker.Image, error) {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.InspectImage(name)
+}
+
+func (dw *_DockerWrapper) PullImage(options docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.PullImage(options, auth)
+}
+
+func (dw *_DockerWrapper) StopContainer(id string, timeout uint) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.StopContainer(id, timeout)
+}
+
+func (dw *_DockerWrapper) RemoveContainer(options docker.RemoveContainerOptions) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.RemoveContainer(options)
+}
+
+func (dw *_DockerWrapper) RemoveVolume(name string) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.RemoveVolume(name)
+}
+
+func (dw *_DockerWrapper) RenameContainer(options docker.RenameContainerOptions) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create a docker client: %s", err)
+	}
+	return client.RenameContainer(options)
+}
@@ -0,0 +1,316 @@
+package localstack
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+// dockertestBackend is the default Backend.  It runs Localstack in a Docker
+// container with dockertest, reusing a running container of the same name.
+type dockertestBackend struct {
+	wrapper    DockerWrapper
+	name       string
+	repository string
+	tag        string
+	data       string
+	scripts    initScripts
+	// hostConfigs adjust the host configuration of a new container.
+	hostConfigs []func(*docker.HostConfig)
+	// env holds extra environment variables of a new container.
+	env []string
+	// events receives the lifecycle events.
+	events *eventSink
+	// readiness is how the backend waits for Localstack.
+	readiness readinessPolicy
+	// labels are extra labels of a new container.
+	labels map[string]string
+
+	// port is the host port the edge port of a new container is published
+	// on.  It is only set when restarting, so the endpoint doesn't change.
+	port string
+
+	// mu guards resource, which is replaced when the container is restarted.
+	mu sync.Mutex
+	// resource is the container once started.
+	resource *dockertest.Resource
+	// initScriptDir is the host directory holding the init scripts
+	// mounted into the container.  It is removed on Stop.
+	initScriptDir string
+}
+
+// Start finds the named container or runs a new one, and waits for the
+// services and init scripts to be ready.
+func (b *dockertestBackend) Start(services *LocalstackServiceCollection) error {
+	localstack, err := getLocalstack(services, b.wrapper, b.name, b.repository, b.tag)
+	if err != nil {
+		return err
+	}
+
+	if localstack != nil {
+		b.events.emit(Event{Type: EventContainerReused, Container: resourceID(localstack)})
+	} else {
+		// If we didn't find a running container before, we spin one up now.
+		options := &dockertest.RunOptions{
+			Repository: b.repository,
+			Tag:        b.tag,
+			Name:       b.name, // If name == "", docker ignores it.
+			Env: []string{
+				fmt.Sprintf("SERVICES=%s", services.GetServiceMap()),
+			},
+			Labels: containerLabels(services, b.name),
+
+			// PortBindings: map[docker.Port][]docker.PortBinding{
+			//	"4566": {{
+			//		HostPort: "4566",
+			//	}},
+			// },
+			// ExposedPorts: []string{"4566"},
+
+		}
+		options.Env = append(options.Env, b.env...)
+		for label, value := range b.labels {
+			options.Labels[label] = value
+		}
+		if b.port != "" {
+			options.PortBindings = map[docker.Port][]docker.PortBinding{
+				"4566/tcp": {{HostPort: b.port}},
+			}
+		}
+		if len(b.data) > 0 {
+			options.Env = append(options.Env, fmt.Sprintf("DATA_DIR=%s", b.data))
+			options.Mounts = []string{"/tmp/localstack/data:/tmp/localstack/data"}
+		}
+		if !b.scripts.empty() {
+			b.initScriptDir, err = b.scripts.stage()
+			if err != nil {
+				return err
+			}
+			options.Mounts = append(options.Mounts, fmt.Sprintf("%s:%s", b.initScriptDir, b.scripts.target))
+		}
+		if err := b.pullImage(); err != nil {
+			os.RemoveAll(b.initScriptDir)
+			return err
+		}
+		start := time.Now()
+		localstack, err = b.wrapper.RunWithOptions(options, b.hostConfigs...)
+		if err != nil {
+			os.RemoveAll(b.initScriptDir)
+			return fmt.Errorf("could not start resource: %s", err)
+		}
+		b.events.emit(Event{Type: EventContainerCreated, Duration: time.Since(start), Container: resourceID(localstack)})
+	}
+	b.mu.Lock()
+	b.resource = localstack
+	b.mu.Unlock()
+
+	// We wait for the services to be ready before we allow the tests
+	// to be run.
+	ready := func(LocalstackService) error {
+		return b.logsContain("Ready.")
+	}
+	if b.readiness.strategy != nil {
+		ready = func(service LocalstackService) error {
+			return b.readiness.strategy.Ready(b, &LocalstackServiceCollection{service})
+		}
+	}
+	deadline := b.readiness.deadline()
+	for _, service := range *services {
+		start := time.Now()
+		attempt := 0
+		if err := b.retry(deadline, func() error {
+			attempt++
+			err := ready(service)
+			if err != nil {
+				b.events.emit(Event{Type: EventReadinessRetry, Duration: time.Since(start),
+					Container: resourceID(localstack), Service: service.Name, Attempt: attempt, Err: err})
+			}
+			return err
+		}); err != nil {
+			return fmt.Errorf("unable to connect to %s: %s", service.Name, err)
+		}
+		b.events.emit(Event{Type: EventServiceReady, Duration: time.Since(start),
+			Container: resourceID(localstack), Service: service.Name, Attempt: attempt})
+	}
+
+	// When init scripts were mounted, they are only done once the
+	// marker script has written to the logs.
+	if b.initScriptDir != "" {
+		if err := b.retry(deadline, func() error {
+			return b.logsContain(initScriptsCompleteMessage)
+		}); err != nil {
+			return fmt.Errorf("init scripts did not complete: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// retry calls op until it succeeds, with dockertest's retries unless the
+// readiness timeout or back-off was set.  The deadline is then shared by
+// every wait of the start.
+func (b *dockertestBackend) retry(deadline time.Time, op func() error) error {
+	if b.readiness.custom() {
+		return b.readiness.retry(deadline, op)
+	}
+	return b.wrapper.Retry(op)
+}
+
+// pullImage pulls the image unless it is present, so the time spent pulling
+// it is reported apart from creating the container.  When the image can't be
+// inspected, pulling it is left to dockertest.
+func (b *dockertestBackend) pullImage() error {
+	image := fmt.Sprintf("%s:%s", b.repository, b.tag)
+	if _, err := b.wrapper.InspectImage(image); !errors.Is(err, docker.ErrNoSuchImage) {
+		return nil
+	}
+	start := time.Now()
+	err := b.wrapper.PullImage(docker.PullImageOptions{Repository: b.repository, Tag: b.tag}, docker.AuthConfiguration{})
+	if err != nil {
+		return fmt.Errorf("could not pull image %s: %s", image, err)
+	}
+	b.events.emit(Event{Type: EventImagePulled, Duration: time.Since(start), Image: image})
+	return nil
+}
+
+// Inspect describes the container as Docker currently sees it.
+func (b *dockertestBackend) Inspect() (*ContainerInfo, error) {
+	id := resourceID(b.container())
+	if id == "" {
+		return nil, errors.New("the container was not created")
+	}
+	container, err := b.wrapper.InspectContainer(id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect container %s: %s", id, err)
+	}
+	info := &ContainerInfo{
+		ID:        container.ID,
+		Name:      strings.TrimPrefix(container.Name, "/"),
+		Running:   container.State.Running,
+		ExitCode:  container.State.ExitCode,
+		OOMKilled: container.State.OOMKilled,
+		Error:     container.State.Error,
+	}
+	if container.Config != nil {
+		info.Image = container.Config.Image
+	}
+	return info, nil
+}
+
+// Logs writes the output of the container so far to w.
+func (b *dockertestBackend) Logs(w io.Writer) error {
+	id := b.container().Container.ID
+	err := b.wrapper.Logs(docker.LogsOptions{
+		Container:    id,
+		OutputStream: w,
+		ErrorStream:  w,
+		Stdout:       true,
+		Stderr:       true,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve logs for container %s: %s", id, err)
+	}
+	return nil
+}
+
+// Exec runs cmd in the container and waits for it to finish.
+func (b *dockertestBackend) Exec(cmd []string, w io.Writer) (int, error) {
+	id := b.container().Container.ID
+	exec, err := b.wrapper.CreateExec(docker.CreateExecOptions{
+		Container:    id,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to create exec in container %s: %s", id, err)
+	}
+	if err := b.wrapper.StartExec(exec.ID, docker.StartExecOptions{OutputStream: w, ErrorStream: w}); err != nil {
+		return 0, fmt.Errorf("unable to run %s: %s", strings.Join(cmd, " "), err)
+	}
+	inspect, err := b.wrapper.InspectExec(exec.ID)
+	if err != nil {
+		return 0, fmt.Errorf("unable to inspect exec %s: %s", exec.ID, err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// Stop purges the container and removes the staged init scripts.
+func (b *dockertestBackend) Stop() error {
+	// You can't defer this because os.Exit doesn't care for defer
+	if err := b.wrapper.Purge(b.container()); err != nil {
+		return fmt.Errorf("could not purge resource: %s", err)
+	}
+
+	if b.initScriptDir != "" {
+		if err := os.RemoveAll(b.initScriptDir); err != nil {
+			return fmt.Errorf("could not remove init scripts: %s", err)
+		}
+	}
+	return nil
+}
+
+// Endpoint returns the URL of the Localstack edge port.
+func (b *dockertestBackend) Endpoint() string {
+	return fmt.Sprintf("http://%s", b.container().GetHostPort("4566/tcp"))
+}
+
+// resourceID returns the ID of the container, which is unknown to some test
+// doubles.
+func resourceID(resource *dockertest.Resource) string {
+	if resource == nil || resource.Container == nil {
+		return ""
+	}
+	return resource.Container.ID
+}
+
+// container returns the current container.
+func (b *dockertestBackend) container() *dockertest.Resource {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.resource
+}
+
+// restart replaces the container with a new one published on the same host
+// port, so the endpoint clients were created with keeps working.
+func (b *dockertestBackend) restart(services *LocalstackServiceCollection) error {
+	port := b.container().GetPort("4566/tcp")
+	if err := b.Stop(); err != nil {
+		return err
+	}
+	b.port = port
+	return b.Start(services)
+}
+
+// logsContain returns nil when the logs of the container contain the
+// expected text.
+func (b *dockertestBackend) logsContain(expected string) error {
+	// We have to use a method that checks the output
+	// of the docker container here because simply checking for
+	// connetivity on the ports doesn't work.
+	buffer := new(bytes.Buffer)
+	if err := b.Logs(buffer); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if strings.Contains(token, expected) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading input: %s", err)
+	}
+	return errors.New("not Ready")
+}
@@ -0,0 +1,153 @@
+package localstack
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serviceEndpointEnv maps the Localstack service names to the suffix of the
+// service specific endpoint variable read by the AWS CLI and SDKs, e.g.
+// AWS_ENDPOINT_URL_S3.
+var serviceEndpointEnv = map[string]string{
+	"apigateway":      "API_GATEWAY",
+	"kinesis":         "KINESIS",
+	"dynamodb":        "DYNAMODB",
+	"dynamodbstreams": "DYNAMODB_STREAMS",
+	"es":              "ELASTICSEARCH_SERVICE",
+	"s3":              "S3",
+	"firehose":        "FIREHOSE",
+	"lambda":          "LAMBDA",
+	"sns":             "SNS",
+	"sqs":             "SQS",
+	"redshift":        "REDSHIFT",
+	"ses":             "SES",
+	"route53":         "ROUTE_53",
+	"cloudformation":  "CLOUDFORMATION",
+	"cloudwatch":      "CLOUDWATCH",
+	"ssm":             "SSM",
+	"secretsmanager":  "SECRETS_MANAGER",
+	"stepfunctions":   "SFN",
+	"logs":            "CLOUDWATCH_LOGS",
+	"sts":             "STS",
+	"iam":             "IAM",
+}
+
+// Env returns the environment variables that point the AWS CLI, the SDKs and
+// other tools at the instance: AWS_ENDPOINT_URL, one AWS_ENDPOINT_URL_<SERVICE>
+// per requested service, the region and the dummy credentials.  Calls go
+// through the proxy when there is one, so they are recorded too.
+func (ls *Localstack) Env() map[string]string {
+	names := make([]string, 0, len(*ls.Services))
+	for _, service := range *ls.Services {
+		names = append(names, service.Name)
+	}
+	return awsEnv(ls.edgeURL(), names)
+}
+
+// Environ returns the current environment with Env applied, in the KEY=value
+// form used by exec.Cmd.Env.
+func (ls *Localstack) Environ() []string {
+	env := ls.Env()
+	var environ []string
+	for _, variable := range os.Environ() {
+		name := strings.SplitN(variable, "=", 2)[0]
+		if _, ok := env[name]; !ok {
+			environ = append(environ, variable)
+		}
+	}
+	for _, name := range sortedKeys(env) {
+		environ = append(environ, fmt.Sprintf("%s=%s", name, env[name]))
+	}
+	return environ
+}
+
+// awsEnv returns the environment variables that point the AWS CLI and SDKs at
+// the given Localstack endpoint.
+func awsEnv(endpoint string, services []string) map[string]string {
+	env := map[string]string{
+		"AWS_ENDPOINT_URL":      endpoint,
+		"AWS_ACCESS_KEY_ID":     testAccessKeyID,
+		"AWS_SECRET_ACCESS_KEY": testSecretAccessKey,
+		"AWS_SESSION_TOKEN":     testSessionToken,
+		"AWS_REGION":            "us-east-1",
+		"AWS_DEFAULT_REGION":    "us-east-1",
+		EndpointEnv:             endpoint,
+	}
+	for _, service := range services {
+		if suffix, ok := serviceEndpointEnv[service]; ok {
+			env["AWS_ENDPOINT_URL_"+suffix] = endpoint
+		}
+	}
+	return env
+}
+
+// WriteAWSConfig writes a profile of the given name pointing at the instance
+// to the AWS CLI config file at path.  The other profiles of an existing file
+// are kept.  Subprocesses use it with AWS_CONFIG_FILE=path and
+// AWS_PROFILE=profile.
+func (ls *Localstack) WriteAWSConfig(path, profile string) error {
+	var existing []byte
+	if contents, err := ioutil.ReadFile(path); err == nil {
+		existing = contents
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read %s: %s", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create the directory of %s: %s", path, err)
+	}
+	config := replaceProfile(string(existing), profile, awsProfile(ls.edgeURL()))
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		return fmt.Errorf("unable to write %s: %s", path, err)
+	}
+	return nil
+}
+
+// awsProfile returns the settings of a profile pointing at the endpoint.
+func awsProfile(endpoint string) map[string]string {
+	return map[string]string{
+		"region":                "us-east-1",
+		"output":                "json",
+		"endpoint_url":          endpoint,
+		"aws_access_key_id":     testAccessKeyID,
+		"aws_secret_access_key": testSecretAccessKey,
+		"aws_session_token":     testSessionToken,
+	}
+}
+
+// replaceProfile returns the config with the named profile replaced by the
+// given settings, or added when it isn't there.
+func replaceProfile(config, profile string, settings map[string]string) string {
+	header := fmt.Sprintf("[profile %s]", profile)
+	if profile == "default" {
+		header = "[default]"
+	}
+
+	var b strings.Builder
+	skipping := false
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "[") {
+			skipping = trimmed == header
+		}
+		if !skipping {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(header)
+	b.WriteString("\n")
+	for _, name := range sortedKeys(settings) {
+		fmt.Fprintf(&b, "%s = %s\n", name, settings[name])
+	}
+	return b.String()
+}
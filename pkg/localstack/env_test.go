@@ -0,0 +1,72 @@
+package localstack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Env(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	stepfunctions, _ := NewLocalstackService("stepfunctions")
+	ls := &Localstack{backend: newTestBackend(), Services: &LocalstackServiceCollection{*s3, *stepfunctions}}
+
+	env := ls.Env()
+	for _, name := range []string{"AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL_SFN", EndpointEnv} {
+		if env[name] != defaultURL {
+			t.Errorf("We were expecting %s to be %s.  Received %q", name, defaultURL, env[name])
+		}
+	}
+	if _, ok := env["AWS_ENDPOINT_URL_SQS"]; ok {
+		t.Error("Only the requested services should have an endpoint.")
+	}
+	if env["AWS_REGION"] != "us-east-1" || env["AWS_ACCESS_KEY_ID"] != testAccessKeyID {
+		t.Errorf("We were expecting the region and dummy credentials.  Received %v", env)
+	}
+
+	os.Setenv("AWS_REGION", "eu-west-1")
+	defer os.Unsetenv("AWS_REGION")
+	var regions []string
+	for _, variable := range ls.Environ() {
+		if strings.HasPrefix(variable, "AWS_REGION=") {
+			regions = append(regions, variable)
+		}
+	}
+	if len(regions) != 1 || regions[0] != "AWS_REGION=us-east-1" {
+		t.Errorf("The environment should be overridden.  Received %v", regions)
+	}
+}
+
+func Test_WriteAWSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "aws", "config")
+	existing := "[default]\nregion = eu-west-1\n\n[profile localstack]\nregion = eu-west-2\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ls := &Localstack{backend: newTestBackend(), Services: &LocalstackServiceCollection{}}
+	for i := 0; i < 2; i++ {
+		if err := ls.WriteAWSConfig(path, "localstack"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents, _ := ioutil.ReadFile(path)
+	expected := "[default]\nregion = eu-west-1\n\n[profile localstack]\n" +
+		"aws_access_key_id = a\naws_secret_access_key = b\naws_session_token = c\n" +
+		"endpoint_url = " + defaultURL + "\noutput = json\nregion = us-east-1\n"
+	if string(contents) != expected {
+		t.Errorf("The profile was not replaced.  Received %q", contents)
+	}
+}
@@ -0,0 +1,178 @@
+package localstack
+
+import (
+	"sync"
+	"time"
+)
+
+// Logger receives the lifecycle events of instances as structured records
+// of a message and key value pairs.  *slog.Logger implements it, and other
+// loggers can be adapted to it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+}
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	// EventImagePulled is sent once the Localstack image has been pulled
+	// because it wasn't present.
+	EventImagePulled EventType = "ImagePulled"
+	// EventContainerCreated is sent once a new container is running.
+	EventContainerCreated EventType = "ContainerCreated"
+	// EventContainerReused is sent when a running container of the requested
+	// name is used instead of creating one.
+	EventContainerReused EventType = "ContainerReused"
+	// EventServiceReady is sent once a requested service is ready.
+	EventServiceReady EventType = "ServiceReady"
+	// EventReadinessRetry is sent each time a service isn't ready yet.
+	EventReadinessRetry EventType = "ReadinessRetry"
+	// EventDestroyed is sent once the instance is destroyed.
+	EventDestroyed EventType = "Destroyed"
+)
+
+// Event describes a step in the lifecycle of an instance.  Only the Docker
+// backend sends the image, container and readiness events.
+type Event struct {
+	Type EventType
+	// Time is when the event happened.
+	Time time.Time
+	// Duration is how long the step took, e.g. pulling the image or waiting
+	// for a service so far.
+	Duration time.Duration
+	// Elapsed is the time since the instance started being created.
+	Elapsed time.Duration
+	// Image is the Localstack image, if relevant.
+	Image string
+	// Container is the container ID, if relevant.
+	Container string
+	// Service is the service name, if relevant.
+	Service string
+	// Attempt counts the readiness checks of a service.
+	Attempt int
+	// Err is why a readiness check or Destroy failed, if it did.
+	Err error
+}
+
+// args returns the fields of the event that are set as key value pairs.
+func (e Event) args() []interface{} {
+	args := []interface{}{"duration", e.Duration, "elapsed", e.Elapsed}
+	if e.Image != "" {
+		args = append(args, "image", e.Image)
+	}
+	if e.Container != "" {
+		args = append(args, "container", e.Container)
+	}
+	if e.Service != "" {
+		args = append(args, "service", e.Service)
+	}
+	if e.Attempt > 0 {
+		args = append(args, "attempt", e.Attempt)
+	}
+	if e.Err != nil {
+		args = append(args, "error", e.Err)
+	}
+	return args
+}
+
+// WithLogger logs the lifecycle events of the instance.  Readiness retries
+// are logged at debug level and the other events at info level.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithEventHandler calls handler with each lifecycle event of the instance,
+// e.g. to report why starting Localstack was slow.  Handlers are called in
+// order, from the goroutine creating or destroying the instance, or from the
+// monitor when it restarts the container.
+func WithEventHandler(handler func(Event)) Option {
+	return func(o *options) {
+		o.eventHandlers = append(o.eventHandlers, handler)
+	}
+}
+
+// eventSink timestamps events, records them in the startup report and
+// passes them to the logger and handlers.  A nil sink drops them.
+type eventSink struct {
+	logger   Logger
+	handlers []func(Event)
+	// start is when the instance started being created.
+	start time.Time
+
+	// mu guards report, which is only recorded to during startup.
+	mu     sync.Mutex
+	report *StartupReport
+}
+
+// newEventSink returns the sink for the logger and handlers of the options,
+// starting the report of the given services.
+func newEventSink(o *options, services *LocalstackServiceCollection) *eventSink {
+	return &eventSink{
+		logger:   o.logger,
+		handlers: o.eventHandlers,
+		start:    time.Now(),
+		report:   newStartupReport(services),
+	}
+}
+
+// startupSucceeded returns the report of a successful startup.  Services
+// the backend didn't report on are ready, as it started.
+func (s *eventSink) startupSucceeded() *StartupReport {
+	report := s.finishReport()
+	for name, status := range report.Services {
+		if status == "unknown" {
+			report.Services[name] = "ready"
+		}
+	}
+	return report
+}
+
+// finishReport completes the report and stops recording to it.
+func (s *eventSink) finishReport() *StartupReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := s.report
+	s.report = nil
+	if report != nil {
+		report.finish(time.Since(s.start))
+	}
+	return report
+}
+
+// startupFailed returns err with the report of the startup, diagnosing the
+// backend when there is one.
+func (s *eventSink) startupFailed(err error, backend Backend) error {
+	report := s.finishReport()
+	if backend != nil {
+		report.diagnose(backend)
+	}
+	return &StartupError{Err: err, Report: report}
+}
+
+func (s *eventSink) emit(e Event) {
+	if s == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Elapsed = e.Time.Sub(s.start)
+	s.mu.Lock()
+	if s.report != nil {
+		s.report.record(e)
+	}
+	s.mu.Unlock()
+	if s.logger != nil {
+		msg := "localstack " + string(e.Type)
+		if e.Type == EventReadinessRetry {
+			s.logger.Debug(msg, e.args()...)
+		} else {
+			s.logger.Info(msg, e.args()...)
+		}
+	}
+	for _, handler := range s.handlers {
+		handler(e)
+	}
+}
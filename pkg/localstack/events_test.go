@@ -0,0 +1,129 @@
+package localstack
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nichobbs/go_localstack/pkg/mock_localstack"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+// testLogger records the messages logged at each level.
+type testLogger struct {
+	debug []string
+	info  []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.debug = append(l.debug, msg)
+}
+
+func (l *testLogger) Info(msg string, args ...interface{}) {
+	l.info = append(l.info, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func Test_NewLocalstack_Events(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	resource := &dockertest.Resource{Container: &docker.Container{ID: "container"}}
+
+	m.
+		EXPECT().
+		ListContainers(gomock.Any()).
+		Times(1).
+		Return(nil, nil)
+	m.
+		EXPECT().
+		InspectImage(fmt.Sprintf("%s:%s", LocalstackRepository, LocalstackTag)).
+		Times(1).
+		Return(nil, docker.ErrNoSuchImage)
+	m.
+		EXPECT().
+		PullImage(docker.PullImageOptions{Repository: LocalstackRepository, Tag: LocalstackTag}, gomock.Any()).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		Return(resource, nil)
+
+	// The service is ready on the second check.
+	ready := false
+	m.
+		EXPECT().
+		Logs(gomock.Any()).
+		Times(2).
+		DoAndReturn(func(opts docker.LogsOptions) error {
+			if ready {
+				fmt.Fprint(opts.OutputStream, "Ready.")
+			}
+			ready = true
+			return nil
+		})
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(op func() error) error {
+			for op() != nil {
+			}
+			return nil
+		})
+	m.
+		EXPECT().
+		Purge(resource).
+		Times(1).
+		Return(nil)
+
+	var events []Event
+	logger := &testLogger{}
+	ls, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithLogger(logger),
+		WithEventHandler(func(e Event) {
+			events = append(events, e)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EventType{EventImagePulled, EventContainerCreated, EventReadinessRetry, EventServiceReady, EventDestroyed}
+	if len(events) != len(expected) {
+		t.Fatalf("We were expecting %v.  Received %v", expected, events)
+	}
+	for i, e := range events {
+		if e.Type != expected[i] || e.Time.IsZero() || e.Elapsed < e.Duration {
+			t.Errorf("We were expecting a timed %s event.  Received %+v", expected[i], e)
+		}
+	}
+	if events[0].Image != "localstack/localstack:"+LocalstackTag || events[1].Container != "container" {
+		t.Errorf("We were expecting the image and container.  Received %+v %+v", events[0], events[1])
+	}
+	if events[2].Attempt != 1 || events[2].Err == nil || events[3].Attempt != 2 || events[3].Service != "sqs" {
+		t.Errorf("We were expecting the readiness attempts.  Received %+v %+v", events[2], events[3])
+	}
+
+	if len(logger.debug) != 1 || logger.debug[0] != "localstack ReadinessRetry" {
+		t.Errorf("We were expecting the retry to be logged at debug level.  Received %v", logger.debug)
+	}
+	if len(logger.info) != 4 {
+		t.Errorf("We were expecting the other events to be logged at info level.  Received %v", logger.info)
+	}
+}
+
+func Test_eventSink_Nil(t *testing.T) {
+	var sink *eventSink
+	sink.emit(Event{Type: EventDestroyed})
+}
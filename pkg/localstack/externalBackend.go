@@ -0,0 +1,117 @@
+package localstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ExternalBackend is a Backend for a Localstack that is already running, for
+// example a docker-compose service or a CI service container.  It is never
+// started or stopped, only checked, so its logs and commands are not
+// available.
+type ExternalBackend struct {
+	endpoint string
+	client   *http.Client
+	// timeout is how long Start waits for Localstack to be ready.
+	timeout time.Duration
+}
+
+// externalTimeout is how long an external Localstack, which may still be
+// starting, is given to become ready.
+const externalTimeout = 2 * time.Minute
+
+// NewExternalBackend returns a backend for the Localstack listening on the
+// given edge URL, e.g. http://localhost:4566.
+func NewExternalBackend(endpoint string) *ExternalBackend {
+	return &ExternalBackend{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		timeout:  externalTimeout,
+	}
+}
+
+// externalHealth is the body of the Localstack health endpoint.
+type externalHealth struct {
+	Services map[string]string `json:"services"`
+}
+
+// Start waits for Localstack to be reachable and, when it reports its
+// health, for the requested services to be running.
+func (b *ExternalBackend) Start(services *LocalstackServiceCollection) error {
+	deadline := time.Now().Add(b.timeout)
+	for {
+		err := b.ready(services)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ready checks the health of Localstack once.
+func (b *ExternalBackend) ready(services *LocalstackServiceCollection) error {
+	health, err := b.health()
+	if err != nil {
+		return err
+	}
+	if len(health.Services) == 0 {
+		return nil
+	}
+	for _, service := range *services {
+		status, ok := health.Services[service.Name]
+		if !ok {
+			return fmt.Errorf("%s is not enabled in the localstack at %s", service.Name, b.endpoint)
+		}
+		if status != "running" && status != "available" {
+			return fmt.Errorf("%s is %s in the localstack at %s", service.Name, status, b.endpoint)
+		}
+	}
+	return nil
+}
+
+// health returns the health reported by Localstack.  Older versions without
+// a health endpoint report no services.
+func (b *ExternalBackend) health() (*externalHealth, error) {
+	response, err := b.client.Get(b.endpoint + "/health")
+	if err != nil {
+		return nil, fmt.Errorf("localstack is not reachable at %s: %s", b.endpoint, err)
+	}
+	defer response.Body.Close()
+
+	health := &externalHealth{}
+	if response.StatusCode == http.StatusOK {
+		// Anything other than the expected JSON is treated as no report.
+		json.NewDecoder(response.Body).Decode(health) //nolint:errcheck
+	}
+	return health, nil
+}
+
+// Inspect reports whether Localstack can be reached.
+func (b *ExternalBackend) Inspect() (*ContainerInfo, error) {
+	_, err := b.health()
+	return &ContainerInfo{Name: b.endpoint, Running: err == nil}, nil
+}
+
+// Logs returns ErrNotSupported, the container isn't managed by the backend.
+func (b *ExternalBackend) Logs(io.Writer) error {
+	return ErrNotSupported
+}
+
+// Exec returns ErrNotSupported, the container isn't managed by the backend.
+func (b *ExternalBackend) Exec([]string, io.Writer) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Stop does nothing, Localstack is left running for whoever started it.
+func (b *ExternalBackend) Stop() error {
+	return nil
+}
+
+// Endpoint returns the edge URL of Localstack.
+func (b *ExternalBackend) Endpoint() string {
+	return b.endpoint
+}
@@ -0,0 +1,134 @@
+package localstack
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// FakeBackend is a Backend for testing code that manages Localstack
+// instances.  AWS calls are served by Handler and everything else is
+// answered from its fields and recorded.
+type FakeBackend struct {
+	// Handler serves the AWS calls.  When nil, every call fails with an
+	// UnsupportedOperation AWS error.
+	Handler http.Handler
+	// Info is returned by Inspect.  Running is set by the backend.
+	Info ContainerInfo
+	// Output is written by Logs.
+	Output string
+	// ExecFunc runs the commands passed to Exec.  When nil, they succeed
+	// without any output.
+	ExecFunc func(cmd []string, w io.Writer) (int, error)
+	// StartErr, when set, is returned by Start.
+	StartErr error
+
+	mu       sync.Mutex
+	listener net.Listener
+	server   *http.Server
+	services *LocalstackServiceCollection
+	commands [][]string
+	stopped  bool
+}
+
+// Start serves Handler on a random local port.
+func (b *FakeBackend) Start(services *LocalstackServiceCollection) error {
+	if b.StartErr != nil {
+		return b.StartErr
+	}
+	handler := b.Handler
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			call := describeCall(r, body)
+			unsupportedOperation(w, &call)
+		})
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("unable to start the fake backend: %s", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listener = listener
+	b.server = &http.Server{Handler: handler}
+	go b.server.Serve(listener) //nolint:errcheck
+	b.services = services
+	b.stopped = false
+	return nil
+}
+
+// Inspect returns Info, running between Start and Stop.
+func (b *FakeBackend) Inspect() (*ContainerInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	info := b.Info
+	info.Running = b.server != nil && !b.stopped
+	return &info, nil
+}
+
+// Logs writes Output to w.
+func (b *FakeBackend) Logs(w io.Writer) error {
+	_, err := io.WriteString(w, b.Output)
+	return err
+}
+
+// Exec records cmd and runs it with ExecFunc.
+func (b *FakeBackend) Exec(cmd []string, w io.Writer) (int, error) {
+	b.mu.Lock()
+	b.commands = append(b.commands, cmd)
+	b.mu.Unlock()
+	if b.ExecFunc == nil {
+		return 0, nil
+	}
+	return b.ExecFunc(cmd, w)
+}
+
+// Stop stops serving.
+func (b *FakeBackend) Stop() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.server != nil && !b.stopped {
+		b.server.Close()
+	}
+	b.stopped = true
+	return nil
+}
+
+// Endpoint returns the URL Handler is served on, or an empty string before
+// it is started.
+func (b *FakeBackend) Endpoint() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.listener == nil {
+		return ""
+	}
+	return fmt.Sprintf("http://%s", b.listener.Addr().String())
+}
+
+// Services returns the services the backend was started for, or nil before
+// it is started.
+func (b *FakeBackend) Services() *LocalstackServiceCollection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.services
+}
+
+// Commands returns the commands passed to Exec, in order.
+func (b *FakeBackend) Commands() [][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]string(nil), b.commands...)
+}
+
+// Stopped returns true once Stop has been called, until Start is called again.
+func (b *FakeBackend) Stopped() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stopped
+}
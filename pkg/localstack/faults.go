@@ -0,0 +1,240 @@
+package localstack
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FaultRule describes a fault injected into the AWS calls sent to Localstack.
+// A rule applies to the calls matching its Service and Operation, and either
+// delays them, fails them with an AWS error, or drops them as a timeout.
+type FaultRule struct {
+	// Service is the signing name of the service the rule applies to.
+	// (I.E. "dynamodb")  Empty matches any service.
+	Service string
+	// Operation is the API operation the rule applies to. (I.E. "PutItem")
+	// Empty matches any operation.
+	Operation string
+
+	// Error is the AWS error code returned instead of forwarding the call.
+	// (I.E. "ThrottlingException")
+	Error string
+	// Status is the HTTP status code returned with Error.  The default is 400.
+	// Setting Status without Error fails the call with the status text as the code.
+	Status int
+	// Latency is added before the call is forwarded or failed.
+	Latency time.Duration
+	// Timeout holds the call for the given duration, or until the client gives
+	// up, then closes the connection without responding.
+	Timeout time.Duration
+
+	// Probability is the chance, between 0 and 1, of the rule applying to a
+	// matching call.  Zero always applies.
+	Probability float64
+	// Count is the number of times the rule applies before it is removed.
+	// Zero applies forever.
+	Count int
+}
+
+// Common fault rules.  Copy and set Service, Operation, Probability or Count
+// as needed.
+var (
+	// ThrottlingFault fails calls with a ThrottlingException.
+	ThrottlingFault = FaultRule{Error: "ThrottlingException", Status: http.StatusBadRequest}
+	// ProvisionedThroughputExceededFault fails DynamoDB calls as if the
+	// table's capacity was exceeded.
+	ProvisionedThroughputExceededFault = FaultRule{
+		Service: "dynamodb", Error: "ProvisionedThroughputExceededException", Status: http.StatusBadRequest,
+	}
+	// InternalErrorFault fails calls with a 500 InternalFailure.
+	InternalErrorFault = FaultRule{Error: "InternalFailure", Status: http.StatusInternalServerError}
+	// TimeoutFault drops calls after 30 seconds, or when the client gives up.
+	TimeoutFault = FaultRule{Timeout: 30 * time.Second}
+)
+
+// matches returns true when the rule applies to the call.
+func (rule *FaultRule) matches(call *Call) bool {
+	return (rule.Service == "" || rule.Service == call.Service) &&
+		(rule.Operation == "" || rule.Operation == call.Operation)
+}
+
+// FaultInjector holds the fault rules applied to the AWS calls sent to a
+// Localstack instance.  Rules can be added and removed while tests run.
+// See WithFaultInjection.
+type FaultInjector struct {
+	mu     sync.Mutex
+	rules  []*FaultRule
+	random *rand.Rand
+}
+
+func newFaultInjector(rules ...FaultRule) *FaultInjector {
+	injector := &FaultInjector{random: rand.New(rand.NewSource(time.Now().UnixNano()))} //nolint:gosec
+	for _, rule := range rules {
+		injector.Add(rule)
+	}
+	return injector
+}
+
+// Add adds a rule and returns a function that removes it again.  Rules are
+// checked in the order they were added, and the first that applies wins.
+func (injector *FaultInjector) Add(rule FaultRule) func() {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+	r := &rule
+	injector.rules = append(injector.rules, r)
+	return func() {
+		injector.remove(r)
+	}
+}
+
+func (injector *FaultInjector) remove(rule *FaultRule) {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+	for i, r := range injector.rules {
+		if r == rule {
+			injector.rules = append(injector.rules[:i], injector.rules[i+1:]...)
+			return
+		}
+	}
+}
+
+// Clear removes every rule.
+func (injector *FaultInjector) Clear() {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+	injector.rules = nil
+}
+
+// Rules returns a copy of the current rules.
+func (injector *FaultInjector) Rules() []FaultRule {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+	rules := make([]FaultRule, 0, len(injector.rules))
+	for _, rule := range injector.rules {
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// next returns the rule that applies to the call, if any, and counts it.
+func (injector *FaultInjector) next(call *Call) *FaultRule {
+	injector.mu.Lock()
+	defer injector.mu.Unlock()
+	for i, rule := range injector.rules {
+		if !rule.matches(call) {
+			continue
+		}
+		if rule.Probability > 0 && injector.random.Float64() >= rule.Probability {
+			continue
+		}
+		applied := *rule
+		if rule.Count > 0 {
+			rule.Count--
+			if rule.Count == 0 {
+				injector.rules = append(injector.rules[:i], injector.rules[i+1:]...)
+			}
+		}
+		return &applied
+	}
+	return nil
+}
+
+// inject applies the rule to the call.  It returns true when the response has
+// been handled, and the call must not be forwarded to Localstack.
+func (rule *FaultRule) inject(w http.ResponseWriter, r *http.Request, call *Call) bool {
+	if rule.Latency > 0 {
+		select {
+		case <-time.After(rule.Latency):
+		case <-r.Context().Done():
+		}
+	}
+
+	if rule.Timeout > 0 {
+		select {
+		case <-time.After(rule.Timeout):
+		case <-r.Context().Done():
+		}
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return true
+	}
+
+	if rule.Error == "" && rule.Status == 0 {
+		return false
+	}
+	status := rule.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	writeAWSError(w, call, status, rule.Error, "injected by go_localstack")
+	return true
+}
+
+// writeAWSError writes an error response in the protocol the call was made with,
+// so the AWS SDK returns an awserr.Error with the given code and message.
+func writeAWSError(w http.ResponseWriter, call *Call, status int, code, message string) {
+	if code == "" {
+		code = http.StatusText(status)
+	}
+
+	switch call.protocol {
+	case protocolJSON:
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": message}) //nolint:errcheck
+	case protocolRESTXML:
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(status)
+		xml.NewEncoder(w).Encode(struct { //nolint:errcheck
+			XMLName xml.Name `xml:"Error"`
+			Code    string
+			Message string
+		}{Code: code, Message: message})
+	case protocolQuery:
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(status)
+		type errorDetail struct {
+			Type    string
+			Code    string
+			Message string
+		}
+		xml.NewEncoder(w).Encode(struct { //nolint:errcheck
+			XMLName   xml.Name `xml:"ErrorResponse"`
+			Error     errorDetail
+			RequestID string `xml:"RequestId"`
+		}{Error: errorDetail{Type: "Sender", Code: code, Message: message}, RequestID: "go-localstack"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Amzn-ErrorType", code)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"message": message}) //nolint:errcheck
+	}
+}
+
+// WithFaultInjection routes every session created for the Localstack instance
+// through an in-process proxy that injects faults into the AWS calls matching
+// the given rules.  Rules can be changed at runtime with Localstack.Faults.
+func WithFaultInjection(rules ...FaultRule) Option {
+	return func(o *options) {
+		o.faults = append(o.faults, rules...)
+		o.injectFaults = true
+	}
+}
+
+// Faults returns the fault injector of the instance, or nil when fault
+// injection wasn't requested.  See WithFaultInjection.
+func (ls *Localstack) Faults() *FaultInjector {
+	if ls.proxy == nil {
+		return nil
+	}
+	return ls.proxy.faults
+}
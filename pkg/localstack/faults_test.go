@@ -0,0 +1,145 @@
+package localstack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// newFaultyLocalstack returns a Localstack routed through a fault injecting
+// proxy in front of a fake edge that accepts every call.
+func newFaultyLocalstack(t *testing.T, rules ...FaultRule) (*Localstack, func()) {
+	edge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte("{}")) //nolint:errcheck
+	}))
+
+	dynamo, _ := NewLocalstackService("dynamodb")
+	queues, _ := NewLocalstackService("sqs")
+	buckets, _ := NewLocalstackService("s3")
+	ls := &Localstack{
+		backend:  newTestBackend(),
+		Services: &LocalstackServiceCollection{*dynamo, *queues, *buckets},
+	}
+	p, err := newEdgeProxy(mustReverseProxy(t, edge.URL), &Recorder{}, newFaultInjector(rules...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls.proxy = p
+
+	return ls, func() {
+		p.Close()
+		edge.Close()
+	}
+}
+
+func awsErrorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func Test_FaultInjector_Errors(t *testing.T) {
+	throttle := ThrottlingFault
+	throttle.Service = "sqs"
+	ls, closer := newFaultyLocalstack(t, ProvisionedThroughputExceededFault, throttle, FaultRule{Service: "s3", Status: 503})
+	defer closer()
+
+	sess := testSession(t, ls).Copy(&aws.Config{MaxRetries: aws.Int(0)})
+
+	_, err := dynamodb.New(sess).GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("users"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}},
+	})
+	if awsErrorCode(err) != "ProvisionedThroughputExceededException" {
+		t.Errorf("We were expecting ProvisionedThroughputExceededException.  Received %v", err)
+	}
+
+	_, err = sqs.New(sess).ListQueues(&sqs.ListQueuesInput{})
+	if awsErrorCode(err) != "ThrottlingException" {
+		t.Errorf("We were expecting ThrottlingException.  Received %v", err)
+	}
+
+	_, err = s3.New(sess).HeadBucket(&s3.HeadBucketInput{Bucket: aws.String("examplebucket")})
+	if err == nil {
+		t.Error("We were expecting the s3 call to fail.")
+	}
+
+	injected := ls.Recorder().Find(func(call Call) bool { return call.Injected })
+	if len(injected) != 3 {
+		t.Errorf("Every call should be recorded as injected.  Received %v", ls.Recorder().Calls())
+	}
+}
+
+func Test_FaultInjector_CountAndRuntimeChanges(t *testing.T) {
+	ls, closer := newFaultyLocalstack(t)
+	defer closer()
+
+	throttle := ThrottlingFault
+	throttle.Operation = "ListTables"
+	throttle.Count = 1
+	ls.Faults().Add(throttle)
+
+	svc, err := ls.DynamoDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The SDK retries the throttled call, which then succeeds.
+	if _, err := svc.ListTables(&dynamodb.ListTablesInput{}); err != nil {
+		t.Fatalf("The retry should have succeeded.  Received %v", err)
+	}
+	calls := ls.Recorder().Filter("dynamodb", "ListTables")
+	if len(calls) != 2 || !calls[0].Injected || calls[1].Injected {
+		t.Errorf("We were expecting one injected call and one retry.  Received %v", calls)
+	}
+	if len(ls.Faults().Rules()) != 0 {
+		t.Error("The rule should be removed once its count is used up.")
+	}
+
+	remove := ls.Faults().Add(FaultRule{Service: "dynamodb", Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if _, err := svc.ListTables(&dynamodb.ListTablesInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("The latency should have been added to the call.")
+	}
+
+	remove()
+	if len(ls.Faults().Rules()) != 0 {
+		t.Error("The rule should have been removed.")
+	}
+}
+
+func Test_FaultInjector_Timeout(t *testing.T) {
+	ls, closer := newFaultyLocalstack(t, FaultRule{Service: "dynamodb", Timeout: 10 * time.Millisecond})
+	defer closer()
+
+	sess := testSession(t, ls).Copy(&aws.Config{MaxRetries: aws.Int(0)})
+	if _, err := dynamodb.New(sess).ListTables(&dynamodb.ListTablesInput{}); err == nil {
+		t.Error("We were expecting the dropped call to fail.")
+	}
+}
+
+func Test_FaultInjector_Probability(t *testing.T) {
+	injector := newFaultInjector(FaultRule{Error: "ThrottlingException", Probability: 0.5})
+
+	applied := 0
+	for i := 0; i < 1000; i++ {
+		if injector.next(&Call{Service: "sqs"}) != nil {
+			applied++
+		}
+	}
+	if applied < 350 || applied > 650 {
+		t.Errorf("The rule should apply to roughly half of the calls.  Applied to %d", applied)
+	}
+}
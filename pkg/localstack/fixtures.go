@@ -0,0 +1,566 @@
+package localstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"gopkg.in/yaml.v2"
+)
+
+// Fixtures describes the AWS resources to create in a Localstack instance.
+// Fixtures are usually loaded from a YAML or JSON file with ApplyFixtures.
+//
+//	s3:
+//	  - bucket: examplebucket
+//	    directory: ./testdata/examplebucket
+//	    objects:
+//	      examplefile: Hello World
+//	dynamodb:
+//	  - table: users
+//	    keys:
+//	      - {name: id, type: S, key: HASH}
+//	    items:
+//	      - {id: "1", name: Bob}
+//	sqs:
+//	  - queue: jobs
+//	    attributes: {VisibilityTimeout: "30"}
+//	sns:
+//	  - topic: events
+//	    subscriptions:
+//	      - {protocol: sqs, endpoint: jobs}
+//	ssm:
+//	  - {name: /app/colour, value: blue}
+//	secrets:
+//	  - {name: db-password, value: hunter2}
+type Fixtures struct {
+	S3       []BucketFixture    `json:"s3" yaml:"s3"`
+	DynamoDB []TableFixture     `json:"dynamodb" yaml:"dynamodb"`
+	SQS      []QueueFixture     `json:"sqs" yaml:"sqs"`
+	SNS      []TopicFixture     `json:"sns" yaml:"sns"`
+	SSM      []ParameterFixture `json:"ssm" yaml:"ssm"`
+	Secrets  []SecretFixture    `json:"secrets" yaml:"secrets"`
+}
+
+// BucketFixture is an S3 bucket along with the objects it contains.
+type BucketFixture struct {
+	Bucket string `json:"bucket" yaml:"bucket"`
+	// Directory is uploaded recursively, using each file's path relative to
+	// the directory as its key.  Relative paths are resolved against the
+	// directory of the fixture file.
+	Directory string `json:"directory" yaml:"directory"`
+	// Objects maps a key to its content.
+	Objects map[string]string `json:"objects" yaml:"objects"`
+}
+
+// TableFixture is a DynamoDB table along with its items.
+type TableFixture struct {
+	Table string            `json:"table" yaml:"table"`
+	Keys  []KeyFixture      `json:"keys" yaml:"keys"`
+	Items []json.RawMessage `json:"items" yaml:"-"`
+
+	yamlItems []interface{}
+}
+
+// KeyFixture is one attribute of a DynamoDB key schema.
+type KeyFixture struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is the attribute type: S, N or B.
+	Type string `json:"type" yaml:"type"`
+	// Key is the key type: HASH or RANGE.
+	Key string `json:"key" yaml:"key"`
+}
+
+// QueueFixture is an SQS queue.
+type QueueFixture struct {
+	Queue      string            `json:"queue" yaml:"queue"`
+	Attributes map[string]string `json:"attributes" yaml:"attributes"`
+}
+
+// TopicFixture is an SNS topic along with its subscriptions.
+type TopicFixture struct {
+	Topic         string                `json:"topic" yaml:"topic"`
+	Subscriptions []SubscriptionFixture `json:"subscriptions" yaml:"subscriptions"`
+}
+
+// SubscriptionFixture is a subscription to an SNS topic.  When the protocol is
+// sqs and the endpoint isn't an ARN, the endpoint is taken to be the name of
+// a queue.
+type SubscriptionFixture struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+}
+
+// ParameterFixture is an SSM parameter.  The type defaults to String.
+type ParameterFixture struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+	Type  string `json:"type" yaml:"type"`
+}
+
+// SecretFixture is a Secrets Manager secret.
+type SecretFixture struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// UnmarshalYAML keeps the items of a table as generic values so they can be
+// converted to DynamoDB attributes later.
+func (table *TableFixture) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TableFixture
+	var raw struct {
+		plain `yaml:",inline"`
+		Items []interface{} `yaml:"items"`
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*table = TableFixture(raw.plain)
+	table.yamlItems = raw.Items
+	return nil
+}
+
+// items returns the items of the table as DynamoDB attribute maps.
+func (table *TableFixture) items() ([]map[string]*dynamodb.AttributeValue, error) {
+	var values []interface{}
+	for _, raw := range table.Items {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	values = append(values, table.yamlItems...)
+
+	var items []map[string]*dynamodb.AttributeValue
+	for _, value := range values {
+		value = normaliseValue(value)
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("items of table %s must be maps", table.Table)
+		}
+		item, err := dynamodbattribute.MarshalMap(value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// normaliseValue converts the map[interface{}]interface{} values produced by the
+// YAML decoder into map[string]interface{} values, and the json.Number values
+// produced by the JSON decoder into DynamoDB numbers.
+func normaliseValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, element := range v {
+			m[fmt.Sprintf("%v", key)] = normaliseValue(element)
+		}
+		return m
+	case map[string]interface{}:
+		for key, element := range v {
+			v[key] = normaliseValue(element)
+		}
+		return v
+	case []interface{}:
+		for i, element := range v {
+			v[i] = normaliseValue(element)
+		}
+		return v
+	case json.Number:
+		return dynamodbattribute.Number(v)
+	default:
+		return value
+	}
+}
+
+// LoadFixtures reads a fixture file.  Files ending in .json are read as JSON,
+// everything else as YAML.  Relative bucket directories are resolved against
+// the directory of the file.
+func LoadFixtures(path string) (*Fixtures, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read fixtures %s: %s", path, err)
+	}
+
+	fixtures := &Fixtures{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		// Unknown fields are rejected, as they are in YAML.
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(fixtures)
+	} else {
+		err = yaml.UnmarshalStrict(content, fixtures)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse fixtures %s: %s", path, err)
+	}
+
+	for i := range fixtures.S3 {
+		dir := fixtures.S3[i].Directory
+		if dir != "" && !filepath.IsAbs(dir) {
+			fixtures.S3[i].Directory = filepath.Join(filepath.Dir(path), dir)
+		}
+	}
+
+	return fixtures, nil
+}
+
+// FixtureResource is a single resource handled while applying fixtures.
+type FixtureResource struct {
+	// Type is the kind of resource. (I.E. "s3 bucket" or "sqs queue")
+	Type string
+	// Name is the name, key or ARN of the resource.
+	Name string
+	// Created is false when the resource already existed and was updated
+	// in place.
+	Created bool
+}
+
+// FixtureReport lists the resources handled while applying fixtures.
+type FixtureReport struct {
+	Resources []FixtureResource
+}
+
+func (report *FixtureReport) add(kind, name string, created bool) {
+	report.Resources = append(report.Resources, FixtureResource{Type: kind, Name: name, Created: created})
+}
+
+// Created returns the resources that didn't exist before the fixtures were applied.
+func (report *FixtureReport) Created() []FixtureResource {
+	var created []FixtureResource
+	for _, resource := range report.Resources {
+		if resource.Created {
+			created = append(created, resource)
+		}
+	}
+	return created
+}
+
+// ApplyFixtures loads a fixture file and creates every resource it describes
+// in the Localstack instance.  Applying the same fixtures twice is safe:
+// resources that already exist are updated rather than recreated.
+func (ls *Localstack) ApplyFixtures(path string) (*FixtureReport, error) {
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := ls.Session()
+	if err != nil {
+		return nil, err
+	}
+	return fixtures.Apply(sess)
+}
+
+// Apply creates every resource described by the fixtures using the given session.
+// The report is returned even on failure, listing what was done before the error.
+func (fixtures *Fixtures) Apply(sess *session.Session) (*FixtureReport, error) {
+	report := &FixtureReport{}
+	steps := []func(*session.Session, *FixtureReport) error{
+		fixtures.applyS3,
+		fixtures.applyDynamoDB,
+		fixtures.applySQS,
+		fixtures.applySNS,
+		fixtures.applySSM,
+		fixtures.applySecrets,
+	}
+	for _, step := range steps {
+		if err := step(sess, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// isAWSErrorCode returns true when err is an awserr.Error with one of the given codes.
+func isAWSErrorCode(err error, codes ...string) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		for _, code := range codes {
+			if aerr.Code() == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (fixtures *Fixtures) applyS3(sess *session.Session, report *FixtureReport) error {
+	svc := s3.New(sess)
+	for _, bucket := range fixtures.S3 {
+		created := true
+		_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket.Bucket)})
+		if isAWSErrorCode(err, s3.ErrCodeBucketAlreadyOwnedByYou, s3.ErrCodeBucketAlreadyExists) {
+			created = false
+		} else if err != nil {
+			return fmt.Errorf("unable to create bucket %s: %s", bucket.Bucket, err)
+		}
+		report.add("s3 bucket", bucket.Bucket, created)
+
+		objects := map[string][]byte{}
+		if bucket.Directory != "" {
+			err := filepath.Walk(bucket.Directory, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				key, err := filepath.Rel(bucket.Directory, path)
+				if err != nil {
+					return err
+				}
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				objects[filepath.ToSlash(key)] = content
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("unable to read objects for bucket %s: %s", bucket.Bucket, err)
+			}
+		}
+		for key, content := range bucket.Objects {
+			objects[key] = []byte(content)
+		}
+
+		for key, content := range objects {
+			_, err := svc.PutObject(&s3.PutObjectInput{
+				Bucket: aws.String(bucket.Bucket),
+				Key:    aws.String(key),
+				Body:   bytes.NewReader(content),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to put object %s in bucket %s: %s", key, bucket.Bucket, err)
+			}
+			report.add("s3 object", fmt.Sprintf("%s/%s", bucket.Bucket, key), created)
+		}
+	}
+	return nil
+}
+
+func (fixtures *Fixtures) applyDynamoDB(sess *session.Session, report *FixtureReport) error {
+	svc := dynamodb.New(sess)
+	for i := range fixtures.DynamoDB {
+		table := &fixtures.DynamoDB[i]
+		input := &dynamodb.CreateTableInput{
+			TableName: aws.String(table.Table),
+			ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(5),
+				WriteCapacityUnits: aws.Int64(5),
+			},
+		}
+		for _, key := range table.Keys {
+			input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+				AttributeName: aws.String(key.Name),
+				AttributeType: aws.String(key.Type),
+			})
+			input.KeySchema = append(input.KeySchema, &dynamodb.KeySchemaElement{
+				AttributeName: aws.String(key.Name),
+				KeyType:       aws.String(key.Key),
+			})
+		}
+
+		created := true
+		_, err := svc.CreateTable(input)
+		if isAWSErrorCode(err, dynamodb.ErrCodeResourceInUseException) {
+			created = false
+		} else if err != nil {
+			return fmt.Errorf("unable to create table %s: %s", table.Table, err)
+		}
+		if err := svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(table.Table)}); err != nil {
+			return fmt.Errorf("table %s did not become active: %s", table.Table, err)
+		}
+		report.add("dynamodb table", table.Table, created)
+
+		items, err := table.items()
+		if err != nil {
+			return fmt.Errorf("unable to read items for table %s: %s", table.Table, err)
+		}
+		for _, item := range items {
+			if _, err := svc.PutItem(&dynamodb.PutItemInput{TableName: aws.String(table.Table), Item: item}); err != nil {
+				return fmt.Errorf("unable to put item in table %s: %s", table.Table, err)
+			}
+		}
+		if len(items) > 0 {
+			report.add("dynamodb items", fmt.Sprintf("%s (%d)", table.Table, len(items)), created)
+		}
+	}
+	return nil
+}
+
+// sqsCreateOnlyAttributes are the queue attributes that can only be given
+// when the queue is created.
+var sqsCreateOnlyAttributes = map[string]bool{"FifoQueue": true}
+
+func (fixtures *Fixtures) applySQS(sess *session.Session, report *FixtureReport) error {
+	svc := sqs.New(sess)
+	for _, queue := range fixtures.SQS {
+		existing, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(queue.Queue)})
+		if err != nil {
+			_, err := svc.CreateQueue(&sqs.CreateQueueInput{
+				QueueName:  aws.String(queue.Queue),
+				Attributes: aws.StringMap(queue.Attributes),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to create queue %s: %s", queue.Queue, err)
+			}
+			report.add("sqs queue", queue.Queue, true)
+			continue
+		}
+
+		// The queue already exists, so only the attributes that can be
+		// changed are set.
+		attributes := map[string]string{}
+		for key, value := range queue.Attributes {
+			if !sqsCreateOnlyAttributes[key] {
+				attributes[key] = value
+			}
+		}
+		if len(attributes) > 0 {
+			_, err := svc.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+				QueueUrl:   existing.QueueUrl,
+				Attributes: aws.StringMap(attributes),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to set attributes of queue %s: %s", queue.Queue, err)
+			}
+		}
+		report.add("sqs queue", queue.Queue, false)
+	}
+	return nil
+}
+
+func (fixtures *Fixtures) applySNS(sess *session.Session, report *FixtureReport) error {
+	svc := sns.New(sess)
+	queues := sqs.New(sess)
+	for _, topic := range fixtures.SNS {
+		existing, err := topicExists(svc, topic.Topic)
+		if err != nil {
+			return fmt.Errorf("unable to list topics: %s", err)
+		}
+
+		output, err := svc.CreateTopic(&sns.CreateTopicInput{Name: aws.String(topic.Topic)})
+		if err != nil {
+			return fmt.Errorf("unable to create topic %s: %s", topic.Topic, err)
+		}
+		report.add("sns topic", topic.Topic, !existing)
+
+		for _, subscription := range topic.Subscriptions {
+			endpoint := subscription.Endpoint
+			if subscription.Protocol == "sqs" && !strings.HasPrefix(endpoint, "arn:") {
+				endpoint, err = queueArn(queues, endpoint)
+				if err != nil {
+					return fmt.Errorf("unable to find queue %s: %s", subscription.Endpoint, err)
+				}
+			}
+			// Subscribing twice with the same protocol and endpoint returns the
+			// existing subscription.
+			_, err := svc.Subscribe(&sns.SubscribeInput{
+				TopicArn: output.TopicArn,
+				Protocol: aws.String(subscription.Protocol),
+				Endpoint: aws.String(endpoint),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to subscribe %s to topic %s: %s", endpoint, topic.Topic, err)
+			}
+			report.add("sns subscription", fmt.Sprintf("%s -> %s", topic.Topic, endpoint), !existing)
+		}
+	}
+	return nil
+}
+
+// topicExists returns true when a topic with the given name exists.
+func topicExists(svc *sns.SNS, name string) (bool, error) {
+	found := false
+	err := svc.ListTopicsPages(&sns.ListTopicsInput{}, func(page *sns.ListTopicsOutput, _ bool) bool {
+		for _, topic := range page.Topics {
+			if strings.HasSuffix(aws.StringValue(topic.TopicArn), ":"+name) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found, err
+}
+
+// queueArn returns the ARN of the named queue.
+func queueArn(svc *sqs.SQS, name string) (string, error) {
+	url, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+	attributes, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       url.QueueUrl,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(attributes.Attributes[sqs.QueueAttributeNameQueueArn]), nil
+}
+
+func (fixtures *Fixtures) applySSM(sess *session.Session, report *FixtureReport) error {
+	svc := ssm.New(sess)
+	for _, parameter := range fixtures.SSM {
+		kind := parameter.Type
+		if kind == "" {
+			kind = ssm.ParameterTypeString
+		}
+
+		created := true
+		_, err := svc.GetParameter(&ssm.GetParameterInput{Name: aws.String(parameter.Name)})
+		if err == nil {
+			created = false
+		}
+
+		_, err = svc.PutParameter(&ssm.PutParameterInput{
+			Name:      aws.String(parameter.Name),
+			Value:     aws.String(parameter.Value),
+			Type:      aws.String(kind),
+			Overwrite: aws.Bool(true),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to put parameter %s: %s", parameter.Name, err)
+		}
+		report.add("ssm parameter", parameter.Name, created)
+	}
+	return nil
+}
+
+func (fixtures *Fixtures) applySecrets(sess *session.Session, report *FixtureReport) error {
+	svc := secretsmanager.New(sess)
+	for _, secret := range fixtures.Secrets {
+		created := true
+		_, err := svc.CreateSecret(&secretsmanager.CreateSecretInput{
+			Name:         aws.String(secret.Name),
+			SecretString: aws.String(secret.Value),
+		})
+		if isAWSErrorCode(err, secretsmanager.ErrCodeResourceExistsException) {
+			created = false
+			_, err = svc.PutSecretValue(&secretsmanager.PutSecretValueInput{
+				SecretId:     aws.String(secret.Name),
+				SecretString: aws.String(secret.Value),
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("unable to create secret %s: %s", secret.Name, err)
+		}
+		report.add("secret", secret.Name, created)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package localstack
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const yamlFixtures = `
+s3:
+  - bucket: examplebucket
+    directory: files
+    objects:
+      examplefile: Hello World
+dynamodb:
+  - table: users
+    keys:
+      - {name: id, type: S, key: HASH}
+    items:
+      - {id: "1", name: Bob, age: 42, tags: [a, b], address: {city: Leeds}}
+sqs:
+  - queue: jobs
+    attributes: {VisibilityTimeout: "30"}
+sns:
+  - topic: events
+    subscriptions:
+      - {protocol: sqs, endpoint: jobs}
+ssm:
+  - {name: /app/colour, value: blue}
+secrets:
+  - {name: db-password, value: hunter2}
+`
+
+const jsonFixtures = `{
+  "dynamodb": [{
+    "table": "users",
+    "keys": [{"name": "id", "type": "S", "key": "HASH"}],
+    "items": [{"id": "1", "name": "Bob", "age": 42, "tags": ["a", "b"], "address": {"city": "Leeds"}}]
+  }]
+}`
+
+func writeFixtures(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_LoadFixtures_YAML(t *testing.T) {
+	path := writeFixtures(t, "fixtures.yaml", yamlFixtures)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures.S3) != 1 || fixtures.S3[0].Objects["examplefile"] != "Hello World" {
+		t.Errorf("The s3 fixtures were not what was expected.  Received %+v", fixtures.S3)
+	}
+	if fixtures.S3[0].Directory != filepath.Join(filepath.Dir(path), "files") {
+		t.Errorf("The bucket directory should be relative to the fixture file.  Received %s", fixtures.S3[0].Directory)
+	}
+	if len(fixtures.SQS) != 1 || fixtures.SQS[0].Attributes["VisibilityTimeout"] != "30" {
+		t.Errorf("The sqs fixtures were not what was expected.  Received %+v", fixtures.SQS)
+	}
+	if len(fixtures.SNS) != 1 || fixtures.SNS[0].Subscriptions[0].Endpoint != "jobs" {
+		t.Errorf("The sns fixtures were not what was expected.  Received %+v", fixtures.SNS)
+	}
+	if len(fixtures.SSM) != 1 || len(fixtures.Secrets) != 1 {
+		t.Error("The ssm and secrets fixtures were not loaded.")
+	}
+
+	items, err := fixtures.DynamoDB[0].items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("We were expecting a single item.  Received %d", len(items))
+	}
+	item := items[0]
+	if aws.StringValue(item["id"].S) != "1" || aws.StringValue(item["age"].N) != "42" {
+		t.Errorf("The item was not converted correctly.  Received %v", item)
+	}
+	if len(item["tags"].L) != 2 || aws.StringValue(item["address"].M["city"].S) != "Leeds" {
+		t.Errorf("The nested values were not converted correctly.  Received %v", item)
+	}
+}
+
+func Test_LoadFixtures_JSON(t *testing.T) {
+	path := writeFixtures(t, "fixtures.json", jsonFixtures)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures.DynamoDB) != 1 || fixtures.DynamoDB[0].Keys[0].Key != "HASH" {
+		t.Fatalf("The dynamodb fixtures were not what was expected.  Received %+v", fixtures.DynamoDB)
+	}
+
+	items, err := fixtures.DynamoDB[0].items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || aws.StringValue(items[0]["age"].N) != "42" {
+		t.Errorf("The item was not converted correctly.  Received %v", items)
+	}
+	if aws.StringValue(items[0]["address"].M["city"].S) != "Leeds" {
+		t.Errorf("The nested values were not converted correctly.  Received %v", items)
+	}
+}
+
+func Test_LoadFixtures_UnknownField(t *testing.T) {
+	path := writeFixtures(t, "fixtures.yml", "s3:\n  - bukket: typo\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	if _, err := LoadFixtures(path); err == nil {
+		t.Error("We were expecting an error for an unknown field.")
+	}
+}
+
+func Test_LoadFixtures_UnknownField_JSON(t *testing.T) {
+	path := writeFixtures(t, "fixtures.json", `{"s3": [{"bukket": "typo"}]}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	if _, err := LoadFixtures(path); err == nil || !strings.Contains(err.Error(), "bukket") {
+		t.Errorf("We were expecting an error for an unknown field.  Received %v", err)
+	}
+}
+
+func Test_FixtureReport_Created(t *testing.T) {
+	report := &FixtureReport{}
+	report.add("sqs queue", "a", true)
+	report.add("sqs queue", "b", false)
+
+	created := report.Created()
+	if len(created) != 1 || created[0].Name != "a" {
+		t.Errorf("Only new resources should be returned.  Received %v", created)
+	}
+}
+
+// sqsFifoRules rejects what SQS rejects for FIFO queues before handing the
+// call on: creating one without FifoQueue, and setting FifoQueue later.
+func sqsFifoRules(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		call := describeCall(r, body)
+		values, _ := url.ParseQuery(string(body))
+		attributes := queueAttributes(values)
+
+		switch values.Get("Action") {
+		case "CreateQueue":
+			if strings.HasSuffix(values.Get("QueueName"), ".fifo") && attributes["FifoQueue"] != "true" {
+				writeAWSError(w, &call, http.StatusBadRequest, "InvalidParameterValue",
+					"The name of a FIFO queue can only include alphanumeric characters, hyphens, or underscores, must end with .fifo suffix")
+				return
+			}
+		case "SetQueueAttributes":
+			if _, ok := attributes["FifoQueue"]; ok {
+				writeAWSError(w, &call, http.StatusBadRequest, "InvalidAttributeName", "Unknown Attribute FifoQueue.")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func Test_ApplyFixtures_FifoQueue(t *testing.T) {
+	queues, _ := NewLocalstackService("sqs")
+	ls, err := NewLocalstack(&LocalstackServiceCollection{*queues},
+		WithBackend(&FakeBackend{Handler: sqsFifoRules(NewMemoryBackend())}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Destroy()
+
+	path := writeFixtures(t, "fixtures.yml", `
+sqs:
+  - queue: jobs.fifo
+    attributes: {FifoQueue: "true", VisibilityTimeout: "30"}
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// The second run finds the queue, which can't be given FifoQueue again.
+	for i := 0; i < 2; i++ {
+		if _, err := ls.ApplyFixtures(path); err != nil {
+			t.Fatalf("We were expecting the fifo queue to be applied on run %d.  Received %v", i+1, err)
+		}
+	}
+
+	svc, _ := ls.SQS()
+	attributes, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(ls.edgeURL() + "/000000000000/jobs.fifo"),
+		AttributeNames: aws.StringSlice([]string{"All"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(attributes.Attributes["FifoQueue"]) != "true" {
+		t.Errorf("We were expecting a fifo queue.  Received %v", attributes.Attributes)
+	}
+}
@@ -0,0 +1,72 @@
+package localstack
+
+import "github.com/ory/dockertest/docker"
+
+// DockerSocket is the path of the Docker socket mounted by WithDockerSocket.
+const DockerSocket = "/var/run/docker.sock"
+
+// cpuPeriod is the CFS period, in microseconds, CPU limits are expressed in.
+const cpuPeriod = 100000
+
+// WithHostConfig adjusts the host configuration of the container when a new
+// one is started.  The options below cover the common settings.  None of
+// them apply to a reused named container, or to other backends.
+func WithHostConfig(hostConfig func(*docker.HostConfig)) Option {
+	return func(o *options) {
+		o.hostConfigs = append(o.hostConfigs, hostConfig)
+	}
+}
+
+// WithMemoryLimit limits the memory of the container, in bytes.
+func WithMemoryLimit(bytes int64) Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.Memory = bytes
+	})
+}
+
+// WithCPULimit limits the container to the given number of CPUs, e.g. 1.5.
+func WithCPULimit(cpus float64) Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.CPUPeriod = cpuPeriod
+		hostConfig.CPUQuota = int64(cpus * cpuPeriod)
+	})
+}
+
+// WithAutoRemove has Docker remove the container once it stops, so it isn't
+// left behind when the test process is killed before Destroy.
+func WithAutoRemove() Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.AutoRemove = true
+	})
+}
+
+// WithRestartPolicy sets the restart policy of the container, e.g.
+// docker.RestartOnFailure(3).
+func WithRestartPolicy(policy docker.RestartPolicy) Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.RestartPolicy = policy
+	})
+}
+
+// WithExtraHosts adds entries, in the "host:ip" form, to the /etc/hosts file
+// of the container.
+func WithExtraHosts(hosts ...string) Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, hosts...)
+	})
+}
+
+// WithPrivileged runs the container in privileged mode.
+func WithPrivileged() Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.Privileged = true
+	})
+}
+
+// WithDockerSocket mounts the Docker socket of the host into the container,
+// which the Lambda docker executor needs to start function containers.
+func WithDockerSocket() Option {
+	return WithHostConfig(func(hostConfig *docker.HostConfig) {
+		hostConfig.Binds = append(hostConfig.Binds, DockerSocket+":"+DockerSocket)
+	})
+}
@@ -0,0 +1,61 @@
+package localstack
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_NewLocalstack_HostConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	hostConfig := &docker.HostConfig{Binds: []string{"/data:/data"}}
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ *dockertest.RunOptions, hcOpts ...func(*docker.HostConfig)) (*dockertest.Resource, error) {
+			for _, hcOpt := range hcOpts {
+				hcOpt(hostConfig)
+			}
+			return &dockertest.Resource{Container: &docker.Container{}}, nil
+		})
+
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithMemoryLimit(512<<20),
+		WithCPULimit(1.5),
+		WithAutoRemove(),
+		WithRestartPolicy(docker.RestartOnFailure(3)),
+		WithExtraHosts("host.docker.internal:host-gateway"),
+		WithPrivileged(),
+		WithDockerSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hostConfig.Memory != 512<<20 || hostConfig.CPUQuota != 150000 || hostConfig.CPUPeriod != 100000 {
+		t.Errorf("The resource limits were not set.  Received %+v", hostConfig)
+	}
+	if !hostConfig.AutoRemove || !hostConfig.Privileged || hostConfig.RestartPolicy.MaximumRetryCount != 3 {
+		t.Errorf("The container settings were not set.  Received %+v", hostConfig)
+	}
+	if len(hostConfig.ExtraHosts) != 1 || len(hostConfig.Binds) != 2 || hostConfig.Binds[1] != DockerSocket+":"+DockerSocket {
+		t.Errorf("The hosts and socket were not added.  Received %v %v", hostConfig.ExtraHosts, hostConfig.Binds)
+	}
+}
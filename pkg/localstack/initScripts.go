@@ -0,0 +1,176 @@
+package localstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InitHookDir is the directory Localstack scans for initialisation scripts
+// once its services are up.  (Used by the 0.x releases of Localstack.)
+const InitHookDir string = "/docker-entrypoint-initaws.d"
+
+// ReadyHookDir is the directory newer releases of Localstack scan for
+// scripts to run once the container is ready.
+const ReadyHookDir string = "/etc/localstack/init/ready.d"
+
+// initScriptsCompleteMessage is written to the container logs by the last
+// script in the hook directory.  Readiness waits for it so tests never run
+// against half-created fixtures.
+const initScriptsCompleteMessage string = "go_localstack: init scripts complete"
+
+// initScriptsCompleteName is the file name of the marker script.  Scripts
+// must sort before it, see checkScriptName, so it is run last.
+const initScriptsCompleteName string = "zzzz-go-localstack-init-complete.sh"
+
+// initScripts describes the scripts that will be mounted into the container.
+type initScripts struct {
+	// dirs are host directories whose files are copied into the hook directory.
+	dirs []string
+	// inline maps a script file name to its content.
+	inline map[string]string
+	// target is the hook directory inside the container.
+	target string
+}
+
+// empty returns true when no scripts have been requested.
+func (scripts *initScripts) empty() bool {
+	return len(scripts.dirs) == 0 && len(scripts.inline) == 0
+}
+
+// stage copies the requested scripts, along with the marker script, into a new
+// temporary directory on the host which can then be mounted into the container.
+// The caller is responsible for removing the returned directory.
+func (scripts *initScripts) stage() (string, error) {
+	dir, err := ioutil.TempDir("", "go_localstack_init")
+	if err != nil {
+		return "", fmt.Errorf("unable to create init script directory: %s", err)
+	}
+
+	for _, src := range scripts.dirs {
+		if err := copyScripts(src, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	names := make([]string, 0, len(scripts.inline))
+	for name := range scripts.inline {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkScriptName(name); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		if err := writeScript(filepath.Join(dir, name), scripts.inline[name]); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	marker := fmt.Sprintf("#!/bin/sh\necho \"%s\"\n", initScriptsCompleteMessage)
+	if err := writeScript(filepath.Join(dir, initScriptsCompleteName), marker); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// copyScripts copies every regular file in src into dst.  Sub directories are ignored.
+func copyScripts(src, dst string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("unable to read init script directory %s: %s", src, err)
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if err := checkScriptName(file.Name()); err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(filepath.Join(src, file.Name()))
+		if err != nil {
+			return fmt.Errorf("unable to read init script %s: %s", file.Name(), err)
+		}
+		if err := writeScript(filepath.Join(dst, file.Name()), string(content)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkScriptName returns an error when the script would be written outside
+// the hook directory, or would be run after the marker script and so after
+// readiness is reported.
+func checkScriptName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid init script name %q, it must be a file name", name)
+	}
+	if strings.HasSuffix(name, ".sh") && name >= initScriptsCompleteName {
+		return fmt.Errorf("init script %s would run after %s, which marks the init scripts complete; "+
+			"rename it to sort before it", name, initScriptsCompleteName)
+	}
+	return nil
+}
+
+// writeScript writes an executable script to the given path.
+func writeScript(path, content string) error {
+	//nolint:gosec
+	if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		return fmt.Errorf("unable to write init script %s: %s", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// WithInitScripts copies every file in the given host directory into the
+// container's initialisation hook directory.  Localstack runs the scripts once
+// its services have started, and the constructor waits until they have all run.
+func WithInitScripts(dir string) Option {
+	return func(o *options) {
+		o.initScripts.dirs = append(o.initScripts.dirs, dir)
+	}
+}
+
+// WithInitScript adds a single inline shell script to the container's
+// initialisation hook directory.  The name is used as the file name, and
+// ".sh" is appended when missing since Localstack only runs shell scripts.
+// Like the scripts of WithInitScripts, it must sort before the script marking
+// the init scripts complete, "zzzz-go-localstack-init-complete.sh".
+func WithInitScript(name, script string) Option {
+	return func(o *options) {
+		if !strings.HasSuffix(name, ".sh") {
+			name += ".sh"
+		}
+		if o.initScripts.inline == nil {
+			o.initScripts.inline = map[string]string{}
+		}
+		o.initScripts.inline[name] = script
+	}
+}
+
+// WithInitHookDir changes the directory inside the container the init scripts
+// are mounted to.  The default is InitHookDir; use ReadyHookDir with newer
+// releases of Localstack.
+func WithInitHookDir(dir string) Option {
+	return func(o *options) {
+		o.initScripts.target = dir
+	}
+}
+
+// WithInitCallback registers a function that is run against the Localstack
+// instance once it is ready and any init scripts have finished.  Callbacks are
+// run in the order they are given.
+func WithInitCallback(callback func(*Localstack) error) Option {
+	return func(o *options) {
+		o.initCallbacks = append(o.initCallbacks, callback)
+	}
+}
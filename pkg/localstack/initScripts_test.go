@@ -0,0 +1,219 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_initScripts_Stage(t *testing.T) {
+	src, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	if err := ioutil.WriteFile(filepath.Join(src, "01-bucket.sh"), []byte("awslocal s3 mb s3://a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "nested"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newOptions(WithInitScripts(src), WithInitScript("02-queue", "awslocal sqs create-queue --queue-name q"))
+	dir, err := o.initScripts.stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+		if f.Mode().Perm()&0100 == 0 {
+			t.Errorf("The script %s should be executable.", f.Name())
+		}
+	}
+
+	expected := []string{"01-bucket.sh", "02-queue.sh", initScriptsCompleteName}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("The staged scripts were not what was expected.  Received %v", names)
+	}
+
+	marker, err := ioutil.ReadFile(filepath.Join(dir, initScriptsCompleteName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(marker), initScriptsCompleteMessage) {
+		t.Error("The marker script should write the completion message.")
+	}
+}
+
+func Test_initScripts_StageInvalidNames(t *testing.T) {
+	for _, name := range []string{"../escape", "hooks/01-bucket", "zzzz-z", "~cleanup"} {
+		o := newOptions(WithInitScript(name, "echo hello"))
+		dir, err := o.initScripts.stage()
+		if err == nil {
+			os.RemoveAll(dir)
+			t.Errorf("We were expecting the script name %q to be rejected.", name)
+		}
+	}
+
+	src, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	if err := ioutil.WriteFile(filepath.Join(src, "~cleanup.sh"), []byte("echo bye"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := newOptions(WithInitScripts(src)).initScripts.stage()
+	if err == nil || !strings.Contains(err.Error(), "~cleanup.sh") {
+		os.RemoveAll(dir)
+		t.Errorf("We were expecting a script sorting after the marker to be rejected.  Received %v", err)
+	}
+}
+
+func Test_initScripts_StageMissingDirectory(t *testing.T) {
+	o := newOptions(WithInitScripts("/does/not/exist"))
+	dir, err := o.initScripts.stage()
+	if err == nil {
+		os.RemoveAll(dir)
+		t.Error("We were expecting an error for a missing script directory.")
+	}
+}
+
+func Test_NewLocalstack_InitScriptsMounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	var mounts []string
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts *dockertest.RunOptions, _ ...func(*docker.HostConfig)) (*dockertest.Resource, error) {
+			mounts = opts.Mounts
+			return &dockertest.Resource{Container: &docker.Container{}}, nil
+		})
+
+	// One for the service and one for the init scripts.
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(2).
+		Return(nil)
+
+	result, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithInitScript("queue", "awslocal sqs create-queue --queue-name q"),
+		WithInitHookDir(ReadyHookDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	initScriptDir := result.backend.(*dockertestBackend).initScriptDir
+	defer os.RemoveAll(initScriptDir)
+
+	expected := fmt.Sprintf("%s:%s", initScriptDir, ReadyHookDir)
+	if len(mounts) != 1 || mounts[0] != expected {
+		t.Errorf("The init scripts were not mounted correctly.  Received %v", mounts)
+	}
+}
+
+func Test_NewLocalstack_InitCallbacks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m, _ := getLocalstackFound(services, ctrl)
+
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	var calls []int
+	result, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithInitCallback(func(ls *Localstack) error {
+			if ls.Services != services {
+				t.Error("The callback should receive the new Localstack instance.")
+			}
+			calls = append(calls, 1)
+			return nil
+		}),
+		WithInitCallback(func(*Localstack) error {
+			calls = append(calls, 2)
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		t.Fatal("We were expecting the returned container to be populated.")
+	}
+
+	if len(calls) != 2 || calls[0] != 1 || calls[1] != 2 {
+		t.Errorf("The callbacks were not run in order.  Received %v", calls)
+	}
+}
+
+func Test_NewLocalstack_InitCallbackFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m, _ := getLocalstackFound(services, ctrl)
+
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	// The container is torn down when a callback fails.
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	dummy := errors.New("dummy Error")
+	result, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithInitCallback(func(*Localstack) error { return dummy }))
+
+	if result != nil {
+		t.Error("We were expecting the returned container to be nil.")
+	}
+
+	if !errors.Is(err, dummy) {
+		t.Errorf("We were expecting the callback error to be returned.  Received %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package localstack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ory/dockertest/docker"
+)
+
+// KeepOnFailureEnv is the environment variable that keeps the containers of
+// failed tests started with NewLocalstackForTest, as WithKeepOnFailure does,
+// e.g. LOCALSTACK_KEEP_ON_FAILURE=true in CI.
+const KeepOnFailureEnv = "LOCALSTACK_KEEP_ON_FAILURE"
+
+// maxKeptTestName is how much of the test name is kept in the name of a kept
+// container.
+const maxKeptTestName = 40
+
+// WithKeepOnFailure keeps the container of a test started with
+// NewLocalstackForTest when the test fails, so it can be inspected, and
+// prints how to reach and remove it.  The kept container is renamed with
+// KeptNamePrefix, and PruneContainers leaves it alone unless all is true.
+func WithKeepOnFailure() Option {
+	return func(o *options) {
+		o.keepOnFailure = true
+	}
+}
+
+// withLabels adds labels to a new container.
+func withLabels(labels map[string]string) Option {
+	return func(o *options) {
+		if o.labels == nil {
+			o.labels = map[string]string{}
+		}
+		for label, value := range labels {
+			o.labels[label] = value
+		}
+	}
+}
+
+// NewLocalstackForTest creates an instance for the test and destroys it when
+// the test finishes.  The test fails when the instance can't be created.  The
+// container is labelled with the test name, and kept when the test fails if
+// WithKeepOnFailure or KeepOnFailureEnv ask for it.
+func NewLocalstackForTest(tb TB, services *LocalstackServiceCollection, opts ...Option) *Localstack {
+	tb.Helper()
+	return newLocalstackForTest(tb, services, &_DockerWrapper{}, opts...)
+}
+
+func newLocalstackForTest(tb TB, services *LocalstackServiceCollection, wrapper DockerWrapper,
+	opts ...Option) *Localstack {
+	tb.Helper()
+
+	keep := newOptions(opts...).keepOnFailure || keepOnFailureFromEnv()
+	opts = append(opts[:len(opts):len(opts)], withLabels(map[string]string{TestLabel: tb.Name()}))
+
+	ls, err := newLocalstack(services, wrapper, "", LocalstackRepository, "latest", opts...)
+	if err != nil {
+		tb.Fatalf("unable to create localstack: %s", err)
+	}
+	tb.Cleanup(func() {
+		if keep && tb.Failed() {
+			if err := ls.DestroyWithOptions(WithKeepContainer(true)); err != nil {
+				tb.Errorf("unable to clean up localstack: %s", err)
+			}
+			// Only now is the container known to be kept, rather than
+			// left behind by a run that was cancelled.
+			if k, ok := ls.backend.(keeper); ok {
+				if err := k.markKept(tb.Name()); err != nil {
+					tb.Errorf("unable to mark localstack as kept: %s", err)
+				}
+			}
+			tb.Log(keptMessage(ls))
+			return
+		}
+		if err := ls.Destroy(); err != nil {
+			tb.Errorf("unable to destroy localstack: %s", err)
+		}
+	})
+	return ls
+}
+
+// keeper is implemented by the backends whose container can be marked as
+// kept, so PruneContainers leaves it alone.
+type keeper interface {
+	markKept(test string) error
+}
+
+// keptName returns the name of the container kept for the test.  The start of
+// the container ID keeps it unique.
+func keptName(test, id string) string {
+	name := sanitizeName(strings.ToLower(test), "-")
+	if len(name) > maxKeptTestName {
+		name = strings.Trim(name[:maxKeptTestName], "-")
+	}
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return fmt.Sprintf("%s%s-%s", KeptNamePrefix, name, id)
+}
+
+// markKept renames the container with KeptNamePrefix.  Named containers keep
+// their name, they are reused by it and never pruned unless asked for.
+func (b *dockertestBackend) markKept(test string) error {
+	if b.name != "" {
+		return nil
+	}
+	id := resourceID(b.container())
+	name := keptName(test, id)
+	if err := b.wrapper.RenameContainer(docker.RenameContainerOptions{ID: id, Name: name}); err != nil {
+		return fmt.Errorf("unable to rename container %s to %s: %s", id, name, err)
+	}
+	return nil
+}
+
+// keepOnFailureFromEnv returns true when KeepOnFailureEnv is set to a true
+// value.
+func keepOnFailureFromEnv() bool {
+	keep, err := strconv.ParseBool(os.Getenv(KeepOnFailureEnv))
+	return err == nil && keep
+}
+
+// keptMessage tells how to reach and remove a kept instance.
+func keptMessage(ls *Localstack) string {
+	var b strings.Builder
+	endpoint := ls.Backend().Endpoint()
+	info, err := ls.Backend().Inspect()
+	if err != nil || info.ID == "" {
+		fmt.Fprintf(&b, "localstack was kept because the test failed\n  endpoint: %s", endpoint)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "localstack container %s was kept because the test failed\n", info.Name)
+	fmt.Fprintf(&b, "  endpoint: %s\n", endpoint)
+	fmt.Fprintf(&b, "  inspect:  aws --endpoint-url %s s3 ls, or docker exec -it %s bash\n", endpoint, info.Name)
+	fmt.Fprintf(&b, "  remove:   docker rm --force --volumes %s", info.Name)
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package localstack
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nichobbs/go_localstack/pkg/mock_localstack"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+// failingTB is a test that has failed, running its cleanups on demand.
+type failingTB struct {
+	testing.TB
+	failed   bool
+	cleanups []func()
+	logs     []string
+}
+
+func (tb *failingTB) Failed() bool {
+	return tb.failed
+}
+
+func (tb *failingTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *failingTB) Log(args ...interface{}) {
+	for _, arg := range args {
+		tb.logs = append(tb.logs, arg.(string))
+	}
+}
+
+func (tb *failingTB) finish() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func Test_NewLocalstackForTest_KeepOnFailure(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+
+	tests := []struct {
+		name   string
+		failed bool
+		opts   []Option
+		env    string
+		kept   bool
+	}{
+		{"failed and kept", true, []Option{WithKeepOnFailure()}, "", true},
+		{"failed and kept by env", true, nil, "true", true},
+		{"passed", false, []Option{WithKeepOnFailure()}, "", false},
+		{"failed without keep", true, nil, "false", false},
+	}
+	if previous, ok := os.LookupEnv(KeepOnFailureEnv); ok {
+		defer os.Setenv(KeepOnFailureEnv, previous)
+	} else {
+		defer os.Unsetenv(KeepOnFailureEnv)
+	}
+	for _, test := range tests {
+		os.Setenv(KeepOnFailureEnv, test.env)
+		fake := &FakeBackend{Info: ContainerInfo{ID: "container", Name: "brave_turing"}}
+		tb := &failingTB{TB: t, failed: test.failed}
+
+		NewLocalstackForTest(tb, services, append(test.opts, WithBackend(fake))...)
+		tb.finish()
+
+		if fake.Stopped() == test.kept {
+			t.Errorf("%s: we were expecting the container to be kept %t.", test.name, test.kept)
+		}
+		if test.kept && (len(tb.logs) != 1 || !strings.Contains(tb.logs[0], "docker rm --force --volumes brave_turing") ||
+			!strings.Contains(tb.logs[0], fake.Endpoint())) {
+			t.Errorf("%s: we were expecting the endpoint and cleanup command.  Received %v", test.name, tb.logs)
+		}
+		if !test.kept && len(tb.logs) != 0 {
+			t.Errorf("%s: we were expecting nothing to be printed.  Received %v", test.name, tb.logs)
+		}
+	}
+}
+
+func Test_NewLocalstackForTest_Labels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		InspectImage(gomock.Any()).
+		AnyTimes().
+		Return(&docker.Image{}, nil)
+
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts *dockertest.RunOptions) (*dockertest.Resource, error) {
+			if opts.Labels[TestLabel] != t.Name() || opts.Labels[ManagedLabel] != "true" {
+				t.Errorf("We were expecting the test labels.  Received %v", opts.Labels)
+			}
+			return &dockertest.Resource{Container: &docker.Container{ID: "container"}}, nil
+		})
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	// The test passes, so the container is removed rather than kept.
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		RenameContainer(gomock.Any()).
+		Times(0)
+
+	tb := &failingTB{TB: t}
+	newLocalstackForTest(tb, services, m, WithKeepOnFailure())
+	tb.finish()
+}
+
+func Test_NewLocalstackForTest_Kept(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	m.
+		EXPECT().
+		InspectImage(gomock.Any()).
+		AnyTimes().
+		Return(&docker.Image{}, nil)
+	id := "0123456789abcdef0123"
+	kept := KeptNamePrefix + "test-newlocalstackfortest-kept-0123456789ab"
+
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		Return(&dockertest.Resource{Container: &docker.Container{ID: id}}, nil)
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(0)
+	m.
+		EXPECT().
+		RenameContainer(docker.RenameContainerOptions{ID: id, Name: kept}).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		InspectContainer(id).
+		Times(1).
+		Return(&docker.Container{ID: id, Name: "/" + kept, State: docker.State{Running: true}}, nil)
+
+	tb := &failingTB{TB: t, failed: true}
+	newLocalstackForTest(tb, services, m, WithKeepOnFailure())
+	tb.finish()
+
+	if len(tb.logs) != 1 || !strings.Contains(tb.logs[0], "docker rm --force --volumes "+kept) {
+		t.Errorf("We were expecting the kept container to be named.  Received %v", tb.logs)
+	}
+}
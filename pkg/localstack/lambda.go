@@ -0,0 +1,268 @@
+package localstack
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// DefaultLambdaRole is the execution role given to functions when none is set.
+// Localstack doesn't check the role, but the Lambda API requires one.
+const DefaultLambdaRole string = "arn:aws:iam::000000000000:role/lambda-role"
+
+// GoFunction describes a Go Lambda function that is built from source and
+// deployed by DeployGoFunction.
+type GoFunction struct {
+	// Name is the name of the Lambda function.
+	Name string
+	// Package is the Go package holding the handler's main function, in any
+	// form accepted by go build. (I.E. "./cmd/handler" or "example.com/handler")
+	Package string
+	// Handler is the name of the binary inside the deployment package, which
+	// the go1.x runtime uses as the handler.  The default is "main".
+	Handler string
+	// Dir is the directory go build is run from.  The default is the current
+	// working directory.
+	Dir string
+	// Role is the execution role of the function.  The default is DefaultLambdaRole.
+	Role string
+	// Environment holds the environment variables of the function.
+	Environment map[string]string
+	// Timeout is the timeout of the function in seconds.  Zero uses the Lambda default.
+	Timeout int64
+	// MemorySize is the memory of the function in MB.  Zero uses the Lambda default.
+	MemorySize int64
+}
+
+// BuildError is returned when a Go Lambda function fails to compile.
+type BuildError struct {
+	// Package is the package that was being built.
+	Package string
+	// Output is the combined output of go build.
+	Output string
+	// Err is the error returned when running go build.
+	Err error
+}
+
+// Error includes the output of go build.
+func (err *BuildError) Error() string {
+	return fmt.Sprintf("unable to build %s: %s\n%s", err.Package, err.Err, err.Output)
+}
+
+// Unwrap returns the error returned when running go build.
+func (err *BuildError) Unwrap() error {
+	return err.Err
+}
+
+// FunctionError is returned by InvokeFunction when the function itself fails.
+type FunctionError struct {
+	// Name is the name of the function.
+	Name string
+	// Type is the kind of failure reported by Lambda. (I.E. "Unhandled")
+	Type string
+	// Payload is the error payload returned by the function.
+	Payload string
+	// Logs are the logs of the invocation.
+	Logs string
+}
+
+// Error includes the logs of the invocation.
+func (err *FunctionError) Error() string {
+	return fmt.Sprintf("function %s failed (%s): %s\n%s", err.Name, err.Type, err.Payload, err.Logs)
+}
+
+// DeployGoFunction cross-compiles a Go Lambda handler for linux/amd64, packages
+// it and creates the function, or updates it when it already exists.  The ARN
+// of the function is returned.  When the build fails, the error is a
+// *BuildError holding the compiler output.
+func (ls *Localstack) DeployGoFunction(ctx context.Context, fn GoFunction) (string, error) {
+	if fn.Handler == "" {
+		fn.Handler = "main"
+	}
+	if fn.Role == "" {
+		fn.Role = DefaultLambdaRole
+	}
+
+	archive, err := buildGoFunction(ctx, fn)
+	if err != nil {
+		return "", err
+	}
+
+	sess, err := ls.Session()
+	if err != nil {
+		return "", err
+	}
+	svc := lambda.New(sess)
+	var environment *lambda.Environment
+	if len(fn.Environment) > 0 {
+		environment = &lambda.Environment{Variables: aws.StringMap(fn.Environment)}
+	}
+	var timeout, memory *int64
+	if fn.Timeout > 0 {
+		timeout = aws.Int64(fn.Timeout)
+	}
+	if fn.MemorySize > 0 {
+		memory = aws.Int64(fn.MemorySize)
+	}
+
+	_, err = svc.GetFunctionWithContext(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(fn.Name)})
+	if err != nil {
+		if !isAWSErrorCode(err, lambda.ErrCodeResourceNotFoundException) {
+			return "", fmt.Errorf("unable to get function %s: %s", fn.Name, err)
+		}
+		output, err := svc.CreateFunctionWithContext(ctx, &lambda.CreateFunctionInput{
+			FunctionName: aws.String(fn.Name),
+			Runtime:      aws.String(lambda.RuntimeGo1X),
+			Handler:      aws.String(fn.Handler),
+			Role:         aws.String(fn.Role),
+			Code:         &lambda.FunctionCode{ZipFile: archive},
+			Environment:  environment,
+			Timeout:      timeout,
+			MemorySize:   memory,
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to create function %s: %s", fn.Name, err)
+		}
+		return aws.StringValue(output.FunctionArn), nil
+	}
+
+	_, err = svc.UpdateFunctionConfigurationWithContext(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(fn.Name),
+		Handler:      aws.String(fn.Handler),
+		Role:         aws.String(fn.Role),
+		Environment:  environment,
+		Timeout:      timeout,
+		MemorySize:   memory,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to update the configuration of function %s: %s", fn.Name, err)
+	}
+	output, err := svc.UpdateFunctionCodeWithContext(ctx, &lambda.UpdateFunctionCodeInput{
+		FunctionName: aws.String(fn.Name),
+		ZipFile:      archive,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to update the code of function %s: %s", fn.Name, err)
+	}
+	return aws.StringValue(output.FunctionArn), nil
+}
+
+// buildGoFunction compiles the function and returns the zipped deployment package.
+func buildGoFunction(ctx context.Context, fn GoFunction) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "go_localstack_lambda")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create build directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, fn.Handler)
+	//nolint:gosec
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", binary, fn.Package)
+	cmd.Dir = fn.Dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, &BuildError{Package: fn.Package, Output: string(output), Err: err}
+	}
+
+	content, err := ioutil.ReadFile(binary)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", binary, err)
+	}
+	return zipHandler(fn.Handler, content)
+}
+
+// zipHandler returns a zip archive holding a single executable file.
+func zipHandler(name string, content []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	archive := zip.NewWriter(buffer)
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	header.SetMode(0755)
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to package %s: %s", name, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, fmt.Errorf("unable to package %s: %s", name, err)
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("unable to package %s: %s", name, err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// InvokeFunction synchronously invokes a Lambda function and returns its
+// response payload.  When the function fails, the error is a *FunctionError
+// holding the logs of the invocation.
+func (ls *Localstack) InvokeFunction(ctx context.Context, name string, payload []byte) ([]byte, error) {
+	sess, err := ls.Session()
+	if err != nil {
+		return nil, err
+	}
+	svc := lambda.New(sess)
+	output, err := svc.InvokeWithContext(ctx, &lambda.InvokeInput{
+		FunctionName: aws.String(name),
+		Payload:      payload,
+		LogType:      aws.String(lambda.LogTypeTail),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to invoke function %s: %s", name, err)
+	}
+
+	if output.FunctionError == nil {
+		return output.Payload, nil
+	}
+
+	var logs string
+	if tail, err := base64.StdEncoding.DecodeString(aws.StringValue(output.LogResult)); err == nil {
+		logs = string(tail)
+	}
+	if strings.TrimSpace(logs) == "" {
+		// Not every executor returns the log tail, so fall back to the log group.
+		if groupLogs, err := ls.FunctionLogs(ctx, name); err == nil {
+			logs = groupLogs
+		}
+	}
+
+	return nil, &FunctionError{
+		Name:    name,
+		Type:    aws.StringValue(output.FunctionError),
+		Payload: string(output.Payload),
+		Logs:    logs,
+	}
+}
+
+// FunctionLogs returns every message in the CloudWatch log group of the function.
+// The logs service must be requested for the logs to be available.
+func (ls *Localstack) FunctionLogs(ctx context.Context, name string) (string, error) {
+	sess, err := ls.Session()
+	if err != nil {
+		return "", err
+	}
+	svc := cloudwatchlogs.New(sess)
+	var b strings.Builder
+	err = svc.FilterLogEventsPagesWithContext(ctx, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(fmt.Sprintf("/aws/lambda/%s", name)),
+	}, func(page *cloudwatchlogs.FilterLogEventsOutput, _ bool) bool {
+		for _, event := range page.Events {
+			b.WriteString(strings.TrimRight(aws.StringValue(event.Message), "\n"))
+			b.WriteString("\n")
+		}
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to read logs of function %s: %s", name, err)
+	}
+	return b.String(), nil
+}
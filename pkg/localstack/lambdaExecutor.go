@@ -0,0 +1,84 @@
+package localstack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LambdaExecutor is how Localstack runs Lambda functions.  See
+// https://github.com/localstack/localstack#configurations
+type LambdaExecutor string
+
+const (
+	// LambdaExecutorLocal runs functions inside the Localstack container.
+	// Only some runtimes are supported.
+	LambdaExecutorLocal LambdaExecutor = "local"
+	// LambdaExecutorDocker runs each invocation in a new container.
+	LambdaExecutorDocker LambdaExecutor = "docker"
+	// LambdaExecutorDockerReuse keeps a container per function running
+	// between invocations.
+	LambdaExecutorDockerReuse LambdaExecutor = "docker-reuse"
+)
+
+// LambdaDockerConfig configures the Lambda docker executors.
+type LambdaDockerConfig struct {
+	// Executor is LambdaExecutorDocker, the default, or
+	// LambdaExecutorDockerReuse.
+	Executor LambdaExecutor
+	// Network is the Docker network the function containers are attached to,
+	// which must let them reach Localstack.  It sets LAMBDA_DOCKER_NETWORK.
+	Network string
+	// MountCode mounts the function code into the function containers rather
+	// than copying it, which only works when the Docker daemon sees the same
+	// file system as Localstack.  It sets LAMBDA_REMOTE_DOCKER=false.
+	MountCode bool
+}
+
+// dockerPingCommand is run inside the container to check the Docker daemon is
+// reachable through the mounted socket.
+var dockerPingCommand = []string{"docker", "ps", "--quiet"}
+
+// WithLambdaDockerExecutor runs Lambda functions in Docker containers.  The
+// Docker socket of the host is mounted into the container, the executor is
+// configured, and once Localstack is ready the constructor fails unless the
+// Docker daemon can be reached from inside the container.
+func WithLambdaDockerExecutor(config LambdaDockerConfig) Option {
+	executor := config.Executor
+	if executor == "" {
+		executor = LambdaExecutorDocker
+	}
+	return func(o *options) {
+		WithDockerSocket()(o)
+		o.env = append(o.env,
+			fmt.Sprintf("LAMBDA_EXECUTOR=%s", executor),
+			fmt.Sprintf("LAMBDA_REMOTE_DOCKER=%t", !config.MountCode))
+		if config.Network != "" {
+			o.env = append(o.env, fmt.Sprintf("LAMBDA_DOCKER_NETWORK=%s", config.Network))
+		}
+		o.initCallbacks = append(o.initCallbacks, checkDockerReachable)
+	}
+}
+
+// checkDockerReachable returns an error when the Docker daemon can't be
+// reached from inside the container.  Backends that can't run commands, and
+// replayed cassettes, are not checked.
+func checkDockerReachable(ls *Localstack) error {
+	if ls.backend == nil {
+		return nil
+	}
+	output := new(bytes.Buffer)
+	code, err := ls.backend.Exec(dockerPingCommand, output)
+	if errors.Is(err, ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check the docker daemon from inside the container: %s", err)
+	}
+	if code != 0 {
+		return fmt.Errorf("the docker daemon is not reachable from inside the container through %s: %s",
+			DockerSocket, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package localstack
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_NewLocalstack_LambdaDockerExecutor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	lambda, _ := NewLocalstackService("lambda")
+	services := &LocalstackServiceCollection{
+		*lambda,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	hostConfig := &docker.HostConfig{}
+	var env []string
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts *dockertest.RunOptions, hcOpts ...func(*docker.HostConfig)) (*dockertest.Resource, error) {
+			env = opts.Env
+			for _, hcOpt := range hcOpts {
+				hcOpt(hostConfig)
+			}
+			return &dockertest.Resource{Container: &docker.Container{ID: "container"}}, nil
+		})
+
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	m.
+		EXPECT().
+		CreateExec(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(opts docker.CreateExecOptions) (*docker.Exec, error) {
+			if strings.Join(opts.Cmd, " ") != "docker ps --quiet" {
+				t.Errorf("We were expecting the docker daemon to be checked.  Received %v", opts.Cmd)
+			}
+			return &docker.Exec{ID: "exec"}, nil
+		})
+	m.
+		EXPECT().
+		StartExec("exec", gomock.Any()).
+		Times(1).
+		Return(nil)
+	m.
+		EXPECT().
+		InspectExec("exec").
+		Times(1).
+		Return(&docker.ExecInspect{ExitCode: 0}, nil)
+
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithLambdaDockerExecutor(LambdaDockerConfig{Executor: LambdaExecutorDockerReuse, Network: "tests"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"LAMBDA_EXECUTOR=docker-reuse", "LAMBDA_REMOTE_DOCKER=true", "LAMBDA_DOCKER_NETWORK=tests"}
+	for _, variable := range expected {
+		found := false
+		for _, e := range env {
+			found = found || e == variable
+		}
+		if !found {
+			t.Errorf("We were expecting %s to be set.  Received %v", variable, env)
+		}
+	}
+	if len(hostConfig.Binds) != 1 || hostConfig.Binds[0] != DockerSocket+":"+DockerSocket {
+		t.Errorf("We were expecting the docker socket to be mounted.  Received %v", hostConfig.Binds)
+	}
+}
+
+func Test_NewLocalstack_LambdaDockerExecutor_Unreachable(t *testing.T) {
+	lambda, _ := NewLocalstackService("lambda")
+	services := &LocalstackServiceCollection{*lambda}
+	fake := &FakeBackend{
+		Output: "Ready.",
+		ExecFunc: func(cmd []string, w io.Writer) (int, error) {
+			fmt.Fprint(w, "Cannot connect to the Docker daemon\n")
+			return 1, nil
+		},
+	}
+
+	_, err := NewLocalstack(services, WithBackend(fake), WithLambdaDockerExecutor(LambdaDockerConfig{}))
+	if err == nil || !strings.Contains(err.Error(), "Cannot connect to the Docker daemon") {
+		t.Errorf("We were expecting the docker daemon to be reported unreachable.  Received %v", err)
+	}
+	if !fake.Stopped() {
+		t.Error("The backend should be stopped when the docker daemon is unreachable.")
+	}
+}
@@ -0,0 +1,76 @@
+package localstack
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeHandler(t *testing.T, source string) string {
+	dir, err := ioutil.TempDir("", "go_localstack_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module handler\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func Test_buildGoFunction(t *testing.T) {
+	dir := writeHandler(t, "package main\n\nfunc main() {}\n")
+	defer os.RemoveAll(dir)
+
+	archive, err := buildGoFunction(context.Background(), GoFunction{Package: ".", Dir: dir, Handler: "handler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != 1 || reader.File[0].Name != "handler" {
+		t.Fatalf("The package should hold only the handler binary.  Received %v", reader.File)
+	}
+	if reader.File[0].Mode().Perm() != 0755 {
+		t.Errorf("The handler should be executable.  Received %s", reader.File[0].Mode())
+	}
+
+	f, err := reader.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := f.Read(magic); err != nil {
+		t.Fatal(err)
+	}
+	if string(magic) != "\x7fELF" {
+		t.Error("The handler should be built for linux.")
+	}
+}
+
+func Test_buildGoFunction_BuildError(t *testing.T) {
+	dir := writeHandler(t, "package main\n\nfunc main() { undefined() }\n")
+	defer os.RemoveAll(dir)
+
+	_, err := buildGoFunction(context.Background(), GoFunction{Package: ".", Dir: dir, Handler: "handler"})
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("We were expecting a BuildError.  Received %v", err)
+	}
+	if !strings.Contains(buildErr.Output, "undefined") {
+		t.Errorf("The error should hold the compiler output.  Received %s", buildErr.Output)
+	}
+}
@@ -11,11 +11,9 @@ Requirements
 package localstack
 
 import (
-	"bufio"
-	"bytes"
-	"errors"
 	"fmt"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -34,28 +32,119 @@ const LocalstackTag string = "0.11.5"
 // Localstack is a structure used to control the lifecycle of the Localstack
 // Docker container.
 type Localstack struct {
-	// Resource is a pointer to the dockertest.Resource
-	// object that is the localstack docker container.
-	// (https://godoc.org/github.com/ory/dockertest#Resource)
-	Resource *dockertest.Resource
 	// Services is a pointer to a collection of service definitions
 	// that are being requested from this particular instance of Localstack.
 	Services *LocalstackServiceCollection
+
+	// strict is true when traffic must never be sent to AWS.
+	strict bool
+	// proxy is the proxy sessions are routed through, if any.
+	proxy *edgeProxy
+	// backend runs Localstack.  It is nil when a cassette is replayed.
+	backend Backend
+	// monitor checks the backend, if asked for.
+	monitor *monitor
+	// events receives the lifecycle events.
+	events *eventSink
+	// report describes how the instance started.
+	report *StartupReport
+	// opts are the options the instance was created with, so its init
+	// callbacks and seeds can be run again, e.g. by a Pool.
+	opts *options
+
+	// shared holds the state copies of the instance share, so Localstack
+	// values can be copied, e.g. by the value receiver of EndpointFor.
+	shared *sharedState
+}
+
+// sharedState is the mutable state of an instance.
+type sharedState struct {
+	// mu guards cleanups and session.
+	mu sync.Mutex
+	// cleanups are run, last first, when the instance is destroyed.
+	cleanups []func() error
+	// session and sessionErr are the cached results of Session.
+	session    *session.Session
+	sessionErr error
+}
+
+// sharedStateMu guards the creation of sharedState.
+var sharedStateMu sync.Mutex
+
+// state returns the shared state of the instance, creating it when needed.
+func (ls *Localstack) state() *sharedState {
+	sharedStateMu.Lock()
+	defer sharedStateMu.Unlock()
+	if ls.shared == nil {
+		ls.shared = &sharedState{}
+	}
+	return ls.shared
 }
 
 // Destroy simply shuts down and cleans up the Localstack container out of docker.
+// Any cleanup registered against the instance (for example by DeployStack) is
+// run first.
 func (ls *Localstack) Destroy() error {
-	pool, err := dockertest.NewPool("")
-	if err != nil {
-		return fmt.Errorf("could not connect to docker: %s", err)
+	return ls.destroy(nil)
+}
+
+// destroy runs the cleanups and stops the backend, gracefully when options
+// are given.
+func (ls *Localstack) destroy(o *destroyOptions) error {
+	// The monitor would otherwise see the container stop.
+	if ls.monitor != nil {
+		ls.monitor.close()
 	}
+	start := time.Now()
+	err := ls.runCleanups()
 
-	// You can't defer this because os.Exit doesn't care for defer
-	if err := pool.Purge(ls.Resource); err != nil {
-		return fmt.Errorf("could not purge resource: %s", err)
+	// The proxy is finished after the cleanups, which may still use it.
+	if ls.proxy != nil {
+		if proxyErr := ls.proxy.finish(); err == nil {
+			err = proxyErr
+		}
 	}
 
-	return nil
+	// Replayed cassettes have no backend.
+	if ls.backend != nil {
+		var stopErr error
+		if o == nil {
+			stopErr = ls.backend.Stop()
+		} else {
+			stopErr = stopBackend(ls.backend, o)
+		}
+		if stopErr != nil {
+			err = stopErr
+		}
+	}
+
+	ls.events.emit(Event{Type: EventDestroyed, Duration: time.Since(start), Err: err})
+	return err
+}
+
+// addCleanup registers a function to be run when the instance is destroyed.
+func (ls *Localstack) addCleanup(cleanup func() error) {
+	state := ls.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.cleanups = append(state.cleanups, cleanup)
+}
+
+// runCleanups runs the registered cleanups, last first, and returns the first error.
+func (ls *Localstack) runCleanups() error {
+	state := ls.state()
+	state.mu.Lock()
+	cleanups := state.cleanups
+	state.cleanups = nil
+	state.mu.Unlock()
+
+	var first error
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if err := cleanups[i](); err != nil && first == nil {
+			first = fmt.Errorf("cleanup failed: %s", err)
+		}
+	}
+	return first
 }
 
 // EndpointResolver is necessary to route traffic to AWS services in your code to the Localstack
@@ -85,34 +174,111 @@ func (ls Localstack) EndpointFor(service, region string, optFns ...func(*endpoin
 		"iam":              "iam"}
 	for k := range availableServices {
 		if k == service && ls.Services.Contains(availableServices[service]) {
-			return endpoints.ResolvedEndpoint{URL: fmt.Sprintf("http://%s", ls.Resource.GetHostPort("4566/tcp"))}, nil
+			return endpoints.ResolvedEndpoint{URL: ls.edgeURL()}, nil
 		}
 	}
+	// In strict mode we fail closed rather than sending traffic to AWS.
+	if ls.strict {
+		return endpoints.ResolvedEndpoint{}, fmt.Errorf("%s is not routed to localstack: %w", service, ErrServiceNotRequested)
+	}
 	return endpoints.DefaultResolver().EndpointFor(service, region, optFns...)
 }
 
+// edgeURL returns the URL sessions send their requests to.  This is the
+// proxy when there is one, otherwise the Localstack edge port.
+func (ls *Localstack) edgeURL() string {
+	if ls.proxy != nil {
+		return ls.proxy.URL()
+	}
+	return ls.containerURL()
+}
+
+// containerURL returns the URL of the Localstack edge port, or of the backend
+// standing in for it.  Replayed cassettes have no backend, the proxy answers
+// in its place.
+func (ls *Localstack) containerURL() string {
+	if ls.backend == nil {
+		if ls.proxy != nil {
+			return ls.proxy.URL()
+		}
+		return ""
+	}
+	return ls.backend.Endpoint()
+}
+
+// Resource returns the dockertest.Resource of the Localstack Docker container,
+// or nil when the instance runs on another backend.
+// (https://godoc.org/github.com/ory/dockertest#Resource)
+func (ls *Localstack) Resource() *dockertest.Resource {
+	if backend, ok := ls.backend.(*dockertestBackend); ok {
+		return backend.container()
+	}
+	return nil
+}
+
 // CreateAWSSession should be used to make sure that your AWS SDK traffic is routing to Localstack correctly.
+// It panics when the session can't be created.  See NewAWSSession.
 func (ls *Localstack) CreateAWSSession() *session.Session {
-	return session.Must(session.NewSession(&aws.Config{
-		Region:           aws.String("us-east-1"),
-		EndpointResolver: *ls,
-		DisableSSL:       aws.Bool(true),
-		S3ForcePathStyle: aws.Bool(true),
-		Credentials:      credentials.NewStaticCredentials("a", "b", "c"),
-	}))
+	return session.Must(ls.NewAWSSession())
+}
+
+// NewAWSSession is the same as CreateAWSSession but returns an error when the
+// session can't be created.  In strict mode, shared AWS config files are
+// ignored and an error is returned when a real AWS profile or role is
+// configured, or the session doesn't use the static Localstack credentials.
+func (ls *Localstack) NewAWSSession() (*session.Session, error) {
+	opts := session.Options{
+		Config: aws.Config{
+			Region:           aws.String("us-east-1"),
+			EndpointResolver: ls,
+			DisableSSL:       aws.Bool(true),
+			S3ForcePathStyle: aws.Bool(true),
+			Credentials:      credentials.NewStaticCredentials(testAccessKeyID, testSecretAccessKey, testSessionToken),
+		},
+	}
+	if !ls.strict {
+		sess, err := session.NewSessionWithOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		return ls.addHealthCheck(sess), nil
+	}
+
+	if err := checkAWSEnvironment(); err != nil {
+		return nil, err
+	}
+	opts.SharedConfigState = session.SharedConfigDisable
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSessionCredentials(sess); err != nil {
+		return nil, err
+	}
+	return ls.addHealthCheck(sess), nil
 }
 
 // NewLocalstack creates a new Localstack docker container based on the latest version.
-func NewLocalstack(services *LocalstackServiceCollection) (*Localstack, error) {
-	return NewSpecificLocalstack(services, "", LocalstackRepository, "latest")
+func NewLocalstack(services *LocalstackServiceCollection, opts ...Option) (*Localstack, error) {
+	return NewSpecificLocalstack(services, "", LocalstackRepository, "latest", opts...)
+}
+
+// Attach returns a Localstack for the instance already listening on the given
+// edge URL, for example a CI service container.  It waits for the requested
+// services to be ready but never creates or removes a container, so Destroy
+// only runs the registered cleanups.  Setting EndpointEnv does the same for
+// NewLocalstack.
+func Attach(url string, services *LocalstackServiceCollection, opts ...Option) (*Localstack, error) {
+	opts = append(opts[:len(opts):len(opts)], WithBackend(NewExternalBackend(url)))
+	return NewLocalstack(services, opts...)
 }
 
-func NewPersistentLocalstack(services *LocalstackServiceCollection, data string) (*Localstack, error) {
-	return NewPersistentSpecificLocalstack(services, "", LocalstackRepository, "latest", data)
+func NewPersistentLocalstack(services *LocalstackServiceCollection, data string, opts ...Option) (*Localstack, error) {
+	return NewPersistentSpecificLocalstack(services, "", LocalstackRepository, "latest", data, opts...)
 }
 
-func NewNamedPersistentLocalstack(services *LocalstackServiceCollection, name, data string) (*Localstack, error) {
-	return NewPersistentSpecificLocalstack(services, name, LocalstackRepository, "latest", data)
+func NewNamedPersistentLocalstack(services *LocalstackServiceCollection, name, data string, opts ...Option) (*Localstack, error) {
+	return NewPersistentSpecificLocalstack(services, name, LocalstackRepository, "latest", data, opts...)
 }
 
 // NewSpecificLocalstack creates a new Localstack docker container based on
@@ -120,12 +286,13 @@ func NewNamedPersistentLocalstack(services *LocalstackServiceCollection, name, d
 // Localstack image.  The behaviour is unknown otherwise.  This method is provided
 // to allow special situations like using a tag other than latest or when referencing
 // an internal Localstack image.
-func NewSpecificLocalstack(services *LocalstackServiceCollection, name, repository, tag string) (*Localstack, error) {
-	return NewPersistentSpecificLocalstack(services, name, repository, tag, "")
+func NewSpecificLocalstack(services *LocalstackServiceCollection, name, repository, tag string, opts ...Option) (*Localstack, error) {
+	return NewPersistentSpecificLocalstack(services, name, repository, tag, "", opts...)
 }
 
-func NewPersistentSpecificLocalstack(services *LocalstackServiceCollection, name, repository, tag, data string) (*Localstack, error) {
-	return newPersistentLocalstack(services, &_DockerWrapper{}, name, repository, tag, data)
+func NewPersistentSpecificLocalstack(services *LocalstackServiceCollection, name, repository, tag, data string,
+	opts ...Option) (*Localstack, error) {
+	return newPersistentLocalstack(services, &_DockerWrapper{}, name, repository, tag, data, opts...)
 }
 
 func getLocalstack(_ *LocalstackServiceCollection, dockerWrapper DockerWrapper, name,
@@ -155,90 +322,127 @@ func getLocalstack(_ *LocalstackServiceCollection, dockerWrapper DockerWrapper,
 }
 
 //nolint:unparam
-func newLocalstack(services *LocalstackServiceCollection, wrapper DockerWrapper, name, repository, tag string) (*Localstack, error) {
-	return newPersistentLocalstack(services, wrapper, name, repository, tag, "")
+func newLocalstack(services *LocalstackServiceCollection, wrapper DockerWrapper, name, repository, tag string,
+	opts ...Option) (*Localstack, error) {
+	return newPersistentLocalstack(services, wrapper, name, repository, tag, "", opts...)
 }
 
 func newPersistentLocalstack(services *LocalstackServiceCollection, wrapper DockerWrapper,
-	name, repository, tag, data string) (*Localstack, error) {
-	localstack, err := getLocalstack(services, wrapper, name, repository, tag)
-	if err != nil {
-		return nil, err
-	}
-
-	if localstack == nil {
-		// Fifth, If we didn't find a running container before, we spin one up now.
-		options := &dockertest.RunOptions{
-			Repository: repository,
-			Tag:        tag,
-			Name:       name, // If name == "", docker ignores it.
-			Env: []string{
-				fmt.Sprintf("SERVICES=%s", services.GetServiceMap()),
-			},
-
-			// PortBindings: map[docker.Port][]docker.PortBinding{
-			//	"4566": {{
-			//		HostPort: "4566",
-			//	}},
-			// },
-			// ExposedPorts: []string{"4566"},
+	name, repository, tag, data string, opts ...Option) (*Localstack, error) {
+	o := newOptions(opts...)
+	events := newEventSink(o, services)
 
+	// First, when a cassette is replayed the recorded responses stand in for
+	// Localstack, so no container is needed.
+	if o.cassettePath != "" {
+		mode, err := resolveCassetteMode(o.cassettePath, o.cassetteMode)
+		if err != nil {
+			return nil, err
 		}
-		if len(data) > 0 {
-			options.Env = append(options.Env, fmt.Sprintf("DATA_DIR=%s", data))
-			options.Mounts = []string{"/tmp/localstack/data:/tmp/localstack/data"}
+		o.cassetteMode = mode
+		if mode == CassetteReplay {
+			ls := &Localstack{Services: services, strict: o.strict, shared: &sharedState{},
+				events: events, report: events.startupSucceeded()}
+			if err := ls.prepare(o); err != nil {
+				return nil, err
+			}
+			return ls, nil
 		}
-		localstack, err = wrapper.RunWithOptions(options)
+	}
+
+	// Second, the backend runs Localstack.  Unless another one was chosen,
+	// this is a Docker container.
+	if o.backend == nil {
+		backend, err := backendFromEnv()
 		if err != nil {
-			return nil, fmt.Errorf("could not start resource: %s", err)
+			return nil, err
 		}
+		o.backend = backend
 	}
+	if o.backend == nil {
+		o.backend = &dockertestBackend{
+			wrapper:    wrapper,
+			name:       name,
+			repository: repository,
+			tag:        tag,
+			data:       data,
+			scripts:    o.initScripts,
 
-	// Sixth, we wait for the services to be ready before we allow the tests
-	// to be run.
-	for _, service := range *services {
-		if err := wrapper.Retry(func() error {
-			// We have to use a method that checks the output
-			// of the docker container here because simply checking for
-			// connetivity on the ports doesn't work.
-			client, err := docker.NewClientFromEnv()
-			if err != nil {
-				return fmt.Errorf("unable to create a docker client: %s", err)
-			}
+			hostConfigs: o.hostConfigs,
+			env:         o.env,
+			events:      events,
+			readiness:   o.readiness,
+			labels:      o.labels,
+		}
+	}
+	if err := o.backend.Start(services); err != nil {
+		return nil, events.startupFailed(err, o.backend)
+	}
+
+	ls := &Localstack{
+		Services: services,
+		strict:   o.strict,
+		backend:  o.backend,
+		shared:   &sharedState{},
+		events:   events,
+		report:   events.startupSucceeded(),
+	}
+	if err := ls.prepare(o); err != nil {
+		return nil, err
+	}
+	if o.monitorInterval > 0 {
+		ls.startMonitor(o.monitorInterval, o.maxRestarts)
+	}
 
-			buffer := new(bytes.Buffer)
+	return ls, nil
+}
 
-			logsOptions := docker.LogsOptions{
-				Container:    localstack.Container.ID,
-				OutputStream: buffer,
-				RawTerminal:  true,
-				Stdout:       true,
-				Stderr:       true,
-			}
-			err = client.Logs(logsOptions)
-			if err != nil {
-				return fmt.Errorf("unable to retrieve logs for container %s: %s", localstack.Container.ID, err)
-			}
+// prepare starts the proxy when one is needed and runs the init callbacks and
+// seeds.  If any of them fail, the instance is destroyed.
+func (ls *Localstack) prepare(o *options) error {
+	if o.record || o.injectFaults || o.cassettePath != "" {
+		if err := ls.startProxy(o); err != nil {
+			return ls.abort(err)
+		}
+	}
 
-			scanner := bufio.NewScanner(buffer)
-			for scanner.Scan() {
-				token := strings.TrimSpace(scanner.Text())
-				expected := "Ready."
-				if strings.Contains(strings.TrimSpace(token), expected) {
-					return nil
-				}
-			}
-			if err := scanner.Err(); err != nil {
-				return fmt.Errorf("reading input: %s", err)
-			}
-			return errors.New("not Ready")
-		}); err != nil {
-			return nil, fmt.Errorf("unable to connect to %s: %s", service.Name, err)
+	// Third, we run the Go hooks now the container is ready.  If any of them
+	// fail, the container is torn down so a half-seeded instance is never used.
+	ls.opts = o
+	if err := ls.initialize(); err != nil {
+		return ls.abort(err)
+	}
+	return nil
+}
+
+// initialize runs the init callbacks and then the seeds, stopping at the
+// first failure.
+func (ls *Localstack) initialize() error {
+	if ls.opts == nil {
+		return nil
+	}
+	for i, callback := range ls.opts.initCallbacks {
+		if err := callback(ls); err != nil {
+			return fmt.Errorf("init callback %d failed: %w", i, err)
 		}
 	}
 
-	return &Localstack{
-		Resource: localstack,
-		Services: services,
-	}, nil
+	for _, s := range ls.opts.seeds {
+		if s.fn == nil {
+			return fmt.Errorf("seed %s has no function", s.name)
+		}
+		if err := s.fn(ls.opts.ctx, ls); err != nil {
+			return fmt.Errorf("seed %s failed: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// abort destroys the Localstack instance after a failure during construction.
+// The returned error wraps the original failure.
+func (ls *Localstack) abort(cause error) error {
+	if err := ls.Destroy(); err != nil {
+		return fmt.Errorf("%w (unable to destroy localstack: %s)", cause, err)
+	}
+	return cause
 }
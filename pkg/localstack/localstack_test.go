@@ -109,6 +109,12 @@ func getLocalstackEmpty(_ *LocalstackServiceCollection, ctrl *gomock.Controller)
 		Times(1).
 		Return(nil, nil)
 
+	m.
+		EXPECT().
+		InspectImage(gomock.Any()).
+		AnyTimes().
+		Return(&docker.Image{}, nil)
+
 	return m
 }
 
@@ -410,7 +416,7 @@ func Test_NewLocalstack_GetLocalstackReturnsResult(t *testing.T) {
 		log.Fatal("We were expecting the returned error to be nil.")
 	}
 
-	if result.Resource.Container != c {
+	if result.Resource().Container != c {
 		log.Fatal("The actual result doesn't match what was expected.")
 	}
 }
@@ -572,7 +578,7 @@ func Test_NewLocalstack_StartFreshContainer_RunWithOptions(t *testing.T) {
 		log.Fatal("The returned collection of services doesn't match what we sent in.")
 	}
 
-	if result.Resource != resource {
+	if result.Resource() != resource {
 		log.Fatal("The returned resource is not what is expected.")
 	}
 }
@@ -645,7 +651,7 @@ func Test_EndpointFor(t *testing.T) {
 		log.Fatal("We were expecting the returned error to be nil.")
 	}
 
-	if result.Resource.Container != c {
+	if result.Resource().Container != c {
 		log.Fatal("The actual result doesn't match what was expected.")
 	}
 
@@ -698,7 +704,7 @@ func Test_EndpointFor_OnlyRegisteredServices(t *testing.T) {
 		log.Fatal("We were expecting the returned error to be nil.")
 	}
 
-	if result.Resource.Container != c {
+	if result.Resource().Container != c {
 		log.Fatal("The actual result doesn't match what was expected.")
 	}
 
@@ -746,7 +752,7 @@ func Test_CreateAWSSession(t *testing.T) {
 		log.Fatal("We were expecting the returned error to be nil.")
 	}
 
-	if result.Resource.Container != c {
+	if result.Resource().Container != c {
 		log.Fatal("The actual result doesn't match what was expected.")
 	}
 
@@ -768,3 +774,41 @@ func Test_CreateAWSSession(t *testing.T) {
 		t.Error("The resulting Resolver shouldn't be nil")
 	}
 }
+
+func Test_Destroy_RunsCleanups(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	m := mock_localstack.NewMockDockerWrapper(ctrl)
+	resource := &dockertest.Resource{}
+
+	var calls []string
+	m.
+		EXPECT().
+		Purge(resource).
+		Times(1).
+		DoAndReturn(func(*dockertest.Resource) error {
+			calls = append(calls, "purge")
+			return nil
+		})
+
+	ls := &Localstack{backend: &dockertestBackend{wrapper: m, resource: resource}}
+	ls.addCleanup(func() error {
+		calls = append(calls, "first")
+		return nil
+	})
+	ls.addCleanup(func() error {
+		calls = append(calls, "second")
+		return errors.New("dummy Error")
+	})
+
+	err := ls.Destroy()
+	if err == nil {
+		t.Error("We were expecting the cleanup error to be returned.")
+	}
+
+	if fmt.Sprint(calls) != "[second first purge]" {
+		t.Errorf("The cleanups should run last first, before the container is purged.  Received %v", calls)
+	}
+}
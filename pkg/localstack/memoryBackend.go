@@ -0,0 +1,134 @@
+package localstack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// memoryAccountID is the account id used in the ARNs and queue URLs of the
+// in-process backend, the same one Localstack uses.
+const memoryAccountID = "000000000000"
+
+// memoryRegion is the region used in the ARNs of the in-process backend.
+const memoryRegion = "us-east-1"
+
+// MemoryBackend is a pure Go, in-memory stand-in for Localstack covering the
+// core S3 object, SQS queue and DynamoDB item APIs.  It starts in milliseconds
+// and needs no Docker.  Calls it doesn't implement fail with an
+// UnsupportedOperation AWS error.  See WithBackend.
+type MemoryBackend struct {
+	listener net.Listener
+	server   *http.Server
+
+	s3       *memoryS3
+	sqs      *memorySQS
+	dynamodb *memoryDynamoDB
+}
+
+// memoryServices are the services implemented by MemoryBackend.
+var memoryServices = []string{"s3", "sqs", "dynamodb"}
+
+// NewMemoryBackend returns a new, empty in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		s3:       newMemoryS3(),
+		sqs:      newMemorySQS(),
+		dynamodb: newMemoryDynamoDB(),
+	}
+}
+
+// Start starts serving on a random local port.  An error is returned when a
+// requested service isn't implemented.
+func (b *MemoryBackend) Start(services *LocalstackServiceCollection) error {
+	for _, service := range *services {
+		if !b.Supports(service.Name) {
+			return fmt.Errorf("the in-memory backend doesn't implement %s, it only implements %s",
+				service.Name, strings.Join(memoryServices, ", "))
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("unable to start the in-memory backend: %s", err)
+	}
+	b.listener = listener
+	b.server = &http.Server{Handler: b}
+	go b.server.Serve(listener) //nolint:errcheck
+	return nil
+}
+
+// Supports returns true when the backend implements the named service.
+func (b *MemoryBackend) Supports(service string) bool {
+	for _, name := range memoryServices {
+		if name == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoint returns the URL of the backend, or an empty string before it is
+// started.
+func (b *MemoryBackend) Endpoint() string {
+	if b.listener == nil {
+		return ""
+	}
+	return fmt.Sprintf("http://%s", b.listener.Addr().String())
+}
+
+// Inspect reports whether the backend is serving.
+func (b *MemoryBackend) Inspect() (*ContainerInfo, error) {
+	return &ContainerInfo{Name: "memory", Running: b.server != nil}, nil
+}
+
+// Logs returns ErrNotSupported, the backend has no output.
+func (b *MemoryBackend) Logs(io.Writer) error {
+	return ErrNotSupported
+}
+
+// Exec returns ErrNotSupported, there is nothing to run commands in.
+func (b *MemoryBackend) Exec([]string, io.Writer) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Stop stops serving.  The stored data is kept.
+func (b *MemoryBackend) Stop() error {
+	if b.server == nil {
+		return nil
+	}
+	server := b.server
+	b.server = nil
+	return server.Shutdown(context.Background())
+}
+
+// ServeHTTP hands the request to the service it was signed for.
+func (b *MemoryBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	call := describeCall(r, body)
+
+	switch call.Service {
+	case "s3":
+		b.s3.serve(w, r, &call, body)
+	case "sqs":
+		b.sqs.serve(w, r, &call, body)
+	case "dynamodb":
+		b.dynamodb.serve(w, &call, body)
+	default:
+		unsupportedOperation(w, &call)
+	}
+}
+
+// unsupportedOperation fails a call the backend doesn't implement.
+func unsupportedOperation(w http.ResponseWriter, call *Call) {
+	writeAWSError(w, call, http.StatusNotImplemented, "UnsupportedOperation",
+		fmt.Sprintf("the in-memory backend doesn't implement %s %s", call.Service, call.Operation))
+}
@@ -0,0 +1,686 @@
+package localstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryDynamoDB holds the tables of the in-process backend.
+type memoryDynamoDB struct {
+	mu     sync.Mutex
+	tables map[string]*memoryTable
+}
+
+// keySchema names the hash and, optionally, range key of a table or index.
+type keySchema struct {
+	hash, rng string
+}
+
+type memoryTable struct {
+	name        string
+	created     time.Time
+	key         keySchema
+	indexes     map[string]keySchema
+	description map[string]interface{}
+	items       map[string]dynamoItem
+}
+
+// dynamoError is a DynamoDB error response.
+type dynamoError struct {
+	code, message string
+}
+
+func validationError(format string, args ...interface{}) *dynamoError {
+	return &dynamoError{"ValidationException", fmt.Sprintf(format, args...)}
+}
+
+// dynamoRequest holds the parameters shared by the DynamoDB operations.
+type dynamoRequest struct {
+	TableName                 string
+	IndexName                 string
+	Item                      dynamoItem
+	Key                       dynamoItem
+	ConditionExpression       string
+	KeyConditionExpression    string
+	FilterExpression          string
+	UpdateExpression          string
+	ProjectionExpression      string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]interface{}
+	ReturnValues              string
+	Limit                     int
+	ExclusiveStartKey         dynamoItem
+	ScanIndexForward          *bool
+	Select                    string
+	ExclusiveStartTableName   string
+
+	KeySchema []struct {
+		AttributeName string
+		KeyType       string
+	}
+
+	RequestItems json.RawMessage
+	Keys         []dynamoItem
+}
+
+func newMemoryDynamoDB() *memoryDynamoDB {
+	return &memoryDynamoDB{tables: map[string]*memoryTable{}}
+}
+
+// serve handles the JSON protocol DynamoDB call described by call.
+func (d *memoryDynamoDB) serve(w http.ResponseWriter, call *Call, body []byte) {
+	var request dynamoRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeAWSError(w, call, http.StatusBadRequest, "SerializationException", err.Error())
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var response interface{}
+	var err *dynamoError
+	switch call.Operation {
+	case "CreateTable":
+		response, err = d.createTable(&request, body)
+	case "DescribeTable":
+		response, err = d.describeTable(&request)
+	case "DeleteTable":
+		response, err = d.deleteTable(&request)
+	case "ListTables":
+		response, err = d.listTables(&request)
+	case "PutItem":
+		response, err = d.putItem(&request)
+	case "GetItem":
+		response, err = d.getItem(&request)
+	case "DeleteItem":
+		response, err = d.deleteItem(&request)
+	case "UpdateItem":
+		response, err = d.updateItem(&request)
+	case "Query", "Scan":
+		response, err = d.query(&request, call.Operation == "Query")
+	case "BatchWriteItem":
+		response, err = d.batchWriteItem(&request)
+	case "BatchGetItem":
+		response, err = d.batchGetItem(&request)
+	default:
+		unsupportedOperation(w, call)
+		return
+	}
+
+	if err != nil {
+		writeAWSError(w, call, http.StatusBadRequest, err.code, err.message)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response) //nolint:errcheck
+}
+
+// table returns the named table.
+func (d *memoryDynamoDB) table(name string) (*memoryTable, *dynamoError) {
+	table, ok := d.tables[name]
+	if !ok {
+		return nil, &dynamoError{"ResourceNotFoundException", fmt.Sprintf("Requested resource not found: Table: %s not found", name)}
+	}
+	return table, nil
+}
+
+func (d *memoryDynamoDB) createTable(request *dynamoRequest, body []byte) (interface{}, *dynamoError) {
+	if _, ok := d.tables[request.TableName]; ok {
+		return nil, &dynamoError{"ResourceInUseException", fmt.Sprintf("Table already exists: %s", request.TableName)}
+	}
+
+	table := &memoryTable{
+		name:    request.TableName,
+		created: time.Now(),
+		indexes: map[string]keySchema{},
+		items:   map[string]dynamoItem{},
+	}
+	for _, element := range request.KeySchema {
+		if element.KeyType == "HASH" {
+			table.key.hash = element.AttributeName
+		} else {
+			table.key.rng = element.AttributeName
+		}
+	}
+	if table.key.hash == "" {
+		return nil, validationError("No Hash Key specified in schema.  All Dynamo DB tables must have exactly one hash key")
+	}
+
+	// The description echoes the request, with the status of the table added.
+	json.Unmarshal(body, &table.description) //nolint:errcheck
+	table.description["TableStatus"] = "ACTIVE"
+	table.description["CreationDateTime"] = float64(table.created.UnixNano()) / float64(time.Second)
+	table.description["TableArn"] = fmt.Sprintf("arn:aws:dynamodb:%s:%s:table/%s", memoryRegion, memoryAccountID, table.name)
+	if billing, ok := table.description["BillingMode"]; ok {
+		table.description["BillingModeSummary"] = map[string]interface{}{"BillingMode": billing}
+		delete(table.description, "BillingMode")
+	}
+	for _, kind := range []string{"GlobalSecondaryIndexes", "LocalSecondaryIndexes"} {
+		indexes, _ := table.description[kind].([]interface{})
+		for _, i := range indexes {
+			index, _ := i.(map[string]interface{})
+			name, _ := index["IndexName"].(string)
+			index["IndexStatus"] = "ACTIVE"
+			index["IndexArn"] = fmt.Sprintf("%s/index/%s", table.description["TableArn"], name)
+			table.indexes[name] = indexKeySchema(index)
+		}
+	}
+
+	d.tables[table.name] = table
+	return map[string]interface{}{"TableDescription": table.describe()}, nil
+}
+
+// indexKeySchema reads the key schema of an index description.
+func indexKeySchema(index map[string]interface{}) keySchema {
+	var schema keySchema
+	elements, _ := index["KeySchema"].([]interface{})
+	for _, e := range elements {
+		element, _ := e.(map[string]interface{})
+		name, _ := element["AttributeName"].(string)
+		if element["KeyType"] == "HASH" {
+			schema.hash = name
+		} else {
+			schema.rng = name
+		}
+	}
+	return schema
+}
+
+// describe returns the description of the table.
+func (table *memoryTable) describe() map[string]interface{} {
+	description := map[string]interface{}{}
+	for key, value := range table.description {
+		description[key] = value
+	}
+	description["ItemCount"] = len(table.items)
+	return description
+}
+
+func (d *memoryDynamoDB) describeTable(request *dynamoRequest) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"Table": table.describe()}, nil
+}
+
+func (d *memoryDynamoDB) deleteTable(request *dynamoRequest) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(d.tables, table.name)
+	description := table.describe()
+	description["TableStatus"] = "DELETING"
+	return map[string]interface{}{"TableDescription": description}, nil
+}
+
+func (d *memoryDynamoDB) listTables(request *dynamoRequest) (interface{}, *dynamoError) {
+	names := []string{}
+	for name := range d.tables {
+		if name > request.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	response := map[string]interface{}{}
+	if request.Limit > 0 && len(names) > request.Limit {
+		names = names[:request.Limit]
+		response["LastEvaluatedTableName"] = names[len(names)-1]
+	}
+	response["TableNames"] = names
+	return response, nil
+}
+
+// itemKey returns the key of the item under the schema, or false when the item
+// doesn't have every key attribute.
+func itemKey(item dynamoItem, schema keySchema) (string, bool) {
+	hash, ok := scalarKey(item[schema.hash])
+	if !ok {
+		return "", false
+	}
+	if schema.rng == "" {
+		return hash, true
+	}
+	rng, ok := scalarKey(item[schema.rng])
+	return hash + "\x00" + rng, ok
+}
+
+// scalarKey encodes an S, N or B key value.
+func scalarKey(value interface{}) (string, bool) {
+	t, v := valueType(value)
+	switch t {
+	case "S", "B":
+		s, ok := v.(string)
+		return t + ":" + s, ok
+	case "N":
+		n, ok := valueNumber(value)
+		if !ok {
+			return "", false
+		}
+		return "N:" + formatNumber(n), true
+	}
+	return "", false
+}
+
+// keyOf validates a Key parameter against the table's schema.
+func (table *memoryTable) keyOf(key dynamoItem) (string, *dynamoError) {
+	id, ok := itemKey(key, table.key)
+	expected := 1
+	if table.key.rng != "" {
+		expected = 2
+	}
+	if !ok || len(key) != expected {
+		return "", validationError("The provided key element does not match the schema")
+	}
+	return id, nil
+}
+
+// expressions parses the expressions of a request and checks every attribute
+// name and value placeholder is used.
+type expressions struct {
+	condition  condition
+	filter     condition
+	keys       condition
+	update     []updateAction
+	updated    []string
+	projection []documentPath
+}
+
+func parseExpressions(request *dynamoRequest) (*expressions, *dynamoError) {
+	e := &expressions{}
+	names, values := request.ExpressionAttributeNames, request.ExpressionAttributeValues
+	var parsers []*expressionParser
+
+	conditions := []struct {
+		expression string
+		target     *condition
+	}{
+		{request.ConditionExpression, &e.condition},
+		{request.FilterExpression, &e.filter},
+		{request.KeyConditionExpression, &e.keys},
+	}
+	for _, c := range conditions {
+		if c.expression == "" {
+			continue
+		}
+		parsed, p, err := parseCondition(c.expression, names, values)
+		if err != nil {
+			return nil, validationError("%s", err)
+		}
+		*c.target = parsed
+		parsers = append(parsers, p)
+	}
+	if request.UpdateExpression != "" {
+		actions, updated, p, err := parseUpdate(request.UpdateExpression, names, values)
+		if err != nil {
+			return nil, validationError("%s", err)
+		}
+		e.update, e.updated = actions, updated
+		parsers = append(parsers, p)
+	}
+	if request.ProjectionExpression != "" {
+		paths, p, err := parseProjection(request.ProjectionExpression, names)
+		if err != nil {
+			return nil, validationError("%s", err)
+		}
+		e.projection = paths
+		parsers = append(parsers, p)
+	}
+
+	used := map[string]bool{}
+	for _, p := range parsers {
+		for placeholder := range p.used {
+			used[placeholder] = true
+		}
+	}
+	for name := range names {
+		if !used[name] {
+			return nil, validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", name)
+		}
+	}
+	for value := range values {
+		if !used[value] {
+			return nil, validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", value)
+		}
+	}
+	return e, nil
+}
+
+// check evaluates the condition expression against the current item.
+func (e *expressions) check(item dynamoItem) *dynamoError {
+	if e.condition == nil {
+		return nil
+	}
+	if item == nil {
+		item = dynamoItem{}
+	}
+	if !e.condition(item) {
+		return &dynamoError{"ConditionalCheckFailedException", "The conditional request failed"}
+	}
+	return nil
+}
+
+// returnValues builds the response of a write from the old and new item.
+func returnValues(request *dynamoRequest, e *expressions, old, updated dynamoItem) (interface{}, *dynamoError) {
+	response := map[string]interface{}{}
+	var attributes dynamoItem
+	switch request.ReturnValues {
+	case "", "NONE":
+	case "ALL_OLD":
+		attributes = old
+	case "ALL_NEW":
+		attributes = updated
+	case "UPDATED_OLD", "UPDATED_NEW":
+		source := old
+		if request.ReturnValues == "UPDATED_NEW" {
+			source = updated
+		}
+		if source != nil {
+			attributes = dynamoItem{}
+			for _, name := range e.updated {
+				if value, ok := source[name]; ok {
+					attributes[name] = value
+				}
+			}
+		}
+	default:
+		return nil, validationError("ReturnValues %s is not valid", request.ReturnValues)
+	}
+	if len(attributes) > 0 {
+		response["Attributes"] = attributes
+	}
+	return response, nil
+}
+
+func (d *memoryDynamoDB) putItem(request *dynamoRequest) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseExpressions(request)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := itemKey(request.Item, table.key)
+	if !ok {
+		return nil, validationError("One or more parameter values were invalid: Missing the key %s in the item", table.key.hash)
+	}
+	old := table.items[id]
+	if err := e.check(old); err != nil {
+		return nil, err
+	}
+	table.items[id] = copyItem(request.Item)
+	return returnValues(request, e, old, request.Item)
+}
+
+func (d *memoryDynamoDB) getItem(request *dynamoRequest) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseExpressions(request)
+	if err != nil {
+		return nil, err
+	}
+	id, err := table.keyOf(request.Key)
+	if err != nil {
+		return nil, err
+	}
+	response := map[string]interface{}{}
+	if item, ok := table.items[id]; ok {
+		response["Item"] = project(item, e.projection)
+	}
+	return response, nil
+}
+
+func (d *memoryDynamoDB) deleteItem(request *dynamoRequest) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseExpressions(request)
+	if err != nil {
+		return nil, err
+	}
+	id, err := table.keyOf(request.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := table.items[id]
+	if err := e.check(old); err != nil {
+		return nil, err
+	}
+	delete(table.items, id)
+	return returnValues(request, e, old, nil)
+}
+
+func (d *memoryDynamoDB) updateItem(request *dynamoRequest) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseExpressions(request)
+	if err != nil {
+		return nil, err
+	}
+	id, err := table.keyOf(request.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := table.items[id]
+	if err := e.check(old); err != nil {
+		return nil, err
+	}
+
+	original := copyItem(old)
+	if original == nil {
+		original = copyItem(request.Key)
+	}
+	updated := copyItem(original)
+	for _, name := range e.updated {
+		if name == table.key.hash || name == table.key.rng {
+			return nil, validationError("Cannot update attribute %s. This attribute is part of the key", name)
+		}
+	}
+	for _, action := range e.update {
+		if err := action(original, updated); err != nil {
+			return nil, validationError("%s", err)
+		}
+	}
+	table.items[id] = updated
+	return returnValues(request, e, old, updated)
+}
+
+// query runs a Query, or a Scan when keyed is false.
+func (d *memoryDynamoDB) query(request *dynamoRequest, keyed bool) (interface{}, *dynamoError) {
+	table, err := d.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseExpressions(request)
+	if err != nil {
+		return nil, err
+	}
+	if keyed && e.keys == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+
+	schema := table.key
+	if request.IndexName != "" {
+		index, ok := table.indexes[request.IndexName]
+		if !ok {
+			return nil, validationError("The table does not have the specified index: %s", request.IndexName)
+		}
+		schema = index
+	}
+
+	// Items are returned in key order, and items missing the index keys are
+	// not in the index.
+	var items []dynamoItem
+	for _, item := range table.items {
+		if _, ok := itemKey(item, schema); ok {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return lessItem(items[i], items[j], schema, table.key)
+	})
+	if request.ScanIndexForward != nil && !*request.ScanIndexForward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if request.ExclusiveStartKey != nil {
+		start, _ := itemKey(request.ExclusiveStartKey, table.key)
+		for i, item := range items {
+			if id, _ := itemKey(item, table.key); id == start {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	matched, scanned := []dynamoItem{}, 0
+	var last dynamoItem
+	truncated := false
+	for _, item := range items {
+		if e.keys != nil && !e.keys(item) {
+			continue
+		}
+		if request.Limit > 0 && scanned == request.Limit {
+			truncated = true
+			break
+		}
+		scanned++
+		last = item
+		if e.filter == nil || e.filter(item) {
+			matched = append(matched, project(item, e.projection))
+		}
+	}
+
+	response := map[string]interface{}{
+		"Count":        len(matched),
+		"ScannedCount": scanned,
+	}
+	if request.Select != "COUNT" {
+		response["Items"] = matched
+	}
+	if truncated {
+		lastKey := dynamoItem{}
+		for _, name := range []string{table.key.hash, table.key.rng, schema.hash, schema.rng} {
+			if value, ok := last[name]; ok && name != "" {
+				lastKey[name] = value
+			}
+		}
+		response["LastEvaluatedKey"] = lastKey
+	}
+	return response, nil
+}
+
+// lessItem orders items by the hash and range key of the schema, then by the
+// table's key.
+func lessItem(a, b dynamoItem, schema, tableKey keySchema) bool {
+	for _, name := range []string{schema.hash, schema.rng} {
+		if name == "" {
+			continue
+		}
+		if c, ok := compareValues(a[name], b[name]); ok && c != 0 {
+			return c < 0
+		}
+	}
+	ka, _ := itemKey(a, tableKey)
+	kb, _ := itemKey(b, tableKey)
+	return ka < kb
+}
+
+func (d *memoryDynamoDB) batchWriteItem(request *dynamoRequest) (interface{}, *dynamoError) {
+	var requestItems map[string][]struct {
+		PutRequest *struct {
+			Item dynamoItem
+		}
+		DeleteRequest *struct {
+			Key dynamoItem
+		}
+	}
+	if err := json.Unmarshal(request.RequestItems, &requestItems); err != nil {
+		return nil, validationError("invalid RequestItems: %s", err)
+	}
+
+	// Every request is validated before any is applied.
+	type write struct {
+		table *memoryTable
+		id    string
+		item  dynamoItem
+	}
+	var writes []write
+	for name, requests := range requestItems {
+		table, err := d.table(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range requests {
+			switch {
+			case r.PutRequest != nil:
+				id, ok := itemKey(r.PutRequest.Item, table.key)
+				if !ok {
+					return nil, validationError("One or more parameter values were invalid: Missing the key %s in the item", table.key.hash)
+				}
+				writes = append(writes, write{table, id, copyItem(r.PutRequest.Item)})
+			case r.DeleteRequest != nil:
+				id, err := table.keyOf(r.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				writes = append(writes, write{table, id, nil})
+			}
+		}
+	}
+	for _, w := range writes {
+		if w.item == nil {
+			delete(w.table.items, w.id)
+		} else {
+			w.table.items[w.id] = w.item
+		}
+	}
+	return map[string]interface{}{"UnprocessedItems": map[string]interface{}{}}, nil
+}
+
+func (d *memoryDynamoDB) batchGetItem(request *dynamoRequest) (interface{}, *dynamoError) {
+	var requestItems map[string]dynamoRequest
+	if err := json.Unmarshal(request.RequestItems, &requestItems); err != nil {
+		return nil, validationError("invalid RequestItems: %s", err)
+	}
+
+	responses := map[string][]dynamoItem{}
+	for name, r := range requestItems {
+		r := r
+		table, err := d.table(name)
+		if err != nil {
+			return nil, err
+		}
+		e, err := parseExpressions(&r)
+		if err != nil {
+			return nil, err
+		}
+		responses[name] = []dynamoItem{}
+		for _, key := range r.Keys {
+			id, err := table.keyOf(key)
+			if err != nil {
+				return nil, err
+			}
+			if item, ok := table.items[id]; ok {
+				responses[name] = append(responses[name], project(item, e.projection))
+			}
+		}
+	}
+	return map[string]interface{}{"Responses": responses, "UnprocessedKeys": map[string]interface{}{}}, nil
+}
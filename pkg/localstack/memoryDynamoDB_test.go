@@ -0,0 +1,179 @@
+package localstack
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type order struct {
+	Customer string   `dynamodbav:"customer"`
+	ID       int      `dynamodbav:"id"`
+	Total    int      `dynamodbav:"total"`
+	Status   string   `dynamodbav:"status,omitempty"`
+	Tags     []string `dynamodbav:"tags,stringset,omitempty"`
+}
+
+func Test_MemoryBackend_DynamoDB(t *testing.T) {
+	ls := newMemoryLocalstack(t, "dynamodb")
+	defer ls.Destroy()
+	svc, err := ls.DynamoDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String("orders"),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("customer"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("id"), AttributeType: aws.String("N")},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("customer"), KeyType: aws.String("HASH")},
+			{AttributeName: aws.String("id"), KeyType: aws.String("RANGE")},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String("orders")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []order{{"ann", 2, 20, "", nil}, {"ann", 10, 100, "", nil}, {"ann", 1, 10, "", nil}, {"bob", 1, 5, "", nil}} {
+		item, _ := dynamodbattribute.MarshalMap(o)
+		if _, err := svc.PutItem(&dynamodb.PutItemInput{TableName: aws.String("orders"), Item: item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item, _ := dynamodbattribute.MarshalMap(order{Customer: "ann", ID: 1, Total: 1})
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:                aws.String("orders"),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#c)"),
+		ExpressionAttributeNames: map[string]*string{"#c": aws.String("customer")},
+	})
+	if awsErrorCode(err) != dynamodb.ErrCodeConditionalCheckFailedException {
+		t.Errorf("We were expecting the condition to fail.  Received %v", err)
+	}
+
+	key := map[string]*dynamodb.AttributeValue{"customer": {S: aws.String("ann")}, "id": {N: aws.String("1")}}
+	updated, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                aws.String("orders"),
+		Key:                      key,
+		UpdateExpression:         aws.String("SET #s = :s, total = total + :n ADD tags :t"),
+		ConditionExpression:      aws.String("total BETWEEN :low AND :high"),
+		ExpressionAttributeNames: map[string]*string{"#s": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":s": {S: aws.String("shipped")}, ":n": {N: aws.String("5")}, ":t": {SS: aws.StringSlice([]string{"fast"})},
+			":low": {N: aws.String("1")}, ":high": {N: aws.String("10")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got order
+	dynamodbattribute.UnmarshalMap(updated.Attributes, &got) //nolint:errcheck
+	if got.Total != 15 || got.Status != "shipped" || len(got.Tags) != 1 {
+		t.Errorf("The item was not updated correctly.  Received %+v", got)
+	}
+
+	query := &dynamodb.QueryInput{
+		TableName:              aws.String("orders"),
+		KeyConditionExpression: aws.String("customer = :c AND id > :id"),
+		FilterExpression:       aws.String("total >= :total"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":c": {S: aws.String("ann")}, ":id": {N: aws.String("0")}, ":total": {N: aws.String("15")},
+		},
+		Limit: aws.Int64(1),
+	}
+	var ids []int
+	err = svc.QueryPages(query, func(page *dynamodb.QueryOutput, _ bool) bool {
+		var orders []order
+		dynamodbattribute.UnmarshalListOfMaps(page.Items, &orders) //nolint:errcheck
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 10 {
+		t.Errorf("We were expecting ann's orders 1, 2 and 10 in numeric order.  Received %v", ids)
+	}
+
+	scan, err := svc.Scan(&dynamodb.ScanInput{TableName: aws.String("orders"), Select: aws.String(dynamodb.SelectCount)})
+	if err != nil || aws.Int64Value(scan.Count) != 4 {
+		t.Errorf("We were expecting four items.  Received %v %v", scan, err)
+	}
+
+	_, err = svc.GetItem(&dynamodb.GetItemInput{
+		TableName:                aws.String("orders"),
+		Key:                      key,
+		ExpressionAttributeNames: map[string]*string{"#unused": aws.String("x")},
+	})
+	if awsErrorCode(err) != "ValidationException" {
+		t.Errorf("We were expecting unused names to be rejected.  Received %v", err)
+	}
+
+	_, err = svc.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: map[string][]*dynamodb.WriteRequest{
+		"orders": {{DeleteRequest: &dynamodb.DeleteRequest{Key: key}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := svc.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{
+		"orders": {Keys: []map[string]*dynamodb.AttributeValue{key, {"customer": {S: aws.String("bob")}, "id": {N: aws.String("1")}}}},
+	}})
+	if err != nil || len(batch.Responses["orders"]) != 1 {
+		t.Errorf("We were expecting only bob's order.  Received %v %v", batch, err)
+	}
+
+	_, err = svc.GetItem(&dynamodb.GetItemInput{TableName: aws.String("missing"), Key: key})
+	if awsErrorCode(err) != dynamodb.ErrCodeResourceNotFoundException {
+		t.Errorf("We were expecting a missing table error.  Received %v", err)
+	}
+}
+
+func Test_parseCondition(t *testing.T) {
+	item := dynamoItem{
+		"name":  map[string]interface{}{"S": "widget"},
+		"price": map[string]interface{}{"N": "9.5"},
+		"tags":  map[string]interface{}{"SS": []interface{}{"a", "b"}},
+		"info":  map[string]interface{}{"M": map[string]interface{}{"colour": map[string]interface{}{"S": "red"}}},
+	}
+	values := map[string]interface{}{
+		":w": map[string]interface{}{"S": "wid"},
+		":p": map[string]interface{}{"N": "10"},
+		":t": map[string]interface{}{"S": "a"},
+		":c": map[string]interface{}{"S": "red"},
+	}
+	tests := map[string]bool{
+		"begins_with(name, :w) AND price < :p":         true,
+		"NOT (price < :p) OR contains(tags, :t)":       true,
+		"info.colour = :c AND attribute_exists(price)": true,
+		"size(name) > :p":                              false,
+		"price IN (:p, :w)":                            false,
+		"attribute_not_exists(missing) AND name <> :w": true,
+	}
+	for expression, expected := range tests {
+		c, _, err := parseCondition(expression, nil, values)
+		if err != nil {
+			t.Errorf("%s: %s", expression, err)
+			continue
+		}
+		if c(item) != expected {
+			t.Errorf("%s should be %t", expression, expected)
+		}
+	}
+
+	if _, _, err := parseCondition("name = :missing", nil, values); err == nil {
+		t.Error("We were expecting an undefined value to fail.")
+	}
+}
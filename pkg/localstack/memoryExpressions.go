@@ -0,0 +1,924 @@
+package localstack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dynamoItem is a DynamoDB item, as decoded from JSON.  Each attribute value is
+// a map with a single type key. (I.E. {"S": "hello"})
+type dynamoItem map[string]interface{}
+
+// copyItem returns a deep copy of an item.
+func copyItem(item dynamoItem) dynamoItem {
+	if item == nil {
+		return nil
+	}
+	contents, _ := json.Marshal(item)
+	var copied dynamoItem
+	json.Unmarshal(contents, &copied) //nolint:errcheck
+	return copied
+}
+
+// valueType returns the type and contents of an attribute value. (I.E. "S" and "hello")
+func valueType(value interface{}) (string, interface{}) {
+	if m, ok := value.(map[string]interface{}); ok {
+		for t, v := range m {
+			return t, v
+		}
+	}
+	return "", nil
+}
+
+// valueNumber parses an N attribute value.
+func valueNumber(value interface{}) (*big.Rat, bool) {
+	t, v := valueType(value)
+	s, ok := v.(string)
+	if t != "N" || !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// formatNumber formats a number the way DynamoDB returns it.
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	return strings.TrimRight(r.FloatString(38), "0")
+}
+
+// compareValues orders two S, N or B attribute values of the same type.
+func compareValues(a, b interface{}) (int, bool) {
+	ta, va := valueType(a)
+	tb, vb := valueType(b)
+	if ta != tb {
+		return 0, false
+	}
+	switch ta {
+	case "S":
+		sa, _ := va.(string)
+		sb, _ := vb.(string)
+		return strings.Compare(sa, sb), true
+	case "N":
+		na, okA := valueNumber(a)
+		nb, okB := valueNumber(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		return na.Cmp(nb), true
+	case "B":
+		sa, _ := va.(string)
+		sb, _ := vb.(string)
+		ba, _ := base64.StdEncoding.DecodeString(sa)
+		bb, _ := base64.StdEncoding.DecodeString(sb)
+		return bytes.Compare(ba, bb), true
+	}
+	return 0, false
+}
+
+// equalValues returns true when two attribute values are equal.
+func equalValues(a, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	ta, va := valueType(a)
+	tb, vb := valueType(b)
+	if ta != tb {
+		return false
+	}
+	if ta == "SS" || ta == "NS" || ta == "BS" {
+		return reflect.DeepEqual(sortedSet(va), sortedSet(vb))
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func sortedSet(v interface{}) []string {
+	items, _ := v.([]interface{})
+	set := make([]string, 0, len(items))
+	for _, item := range items {
+		set = append(set, fmt.Sprint(item))
+	}
+	sort.Strings(set)
+	return set
+}
+
+// pathElement is one step of a document path. (I.E. "a", "[0]")
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type documentPath []pathElement
+
+func (path documentPath) String() string {
+	var b strings.Builder
+	for i, element := range path {
+		switch {
+		case element.isIndex:
+			fmt.Fprintf(&b, "[%d]", element.index)
+		case i > 0:
+			fmt.Fprintf(&b, ".%s", element.name)
+		default:
+			b.WriteString(element.name)
+		}
+	}
+	return b.String()
+}
+
+// get returns the value at the path in the item.
+func (path documentPath) get(item dynamoItem) (interface{}, bool) {
+	value, ok := item[path[0].name]
+	for _, element := range path[1:] {
+		if !ok {
+			return nil, false
+		}
+		value, ok = child(value, element)
+	}
+	return value, ok
+}
+
+func child(value interface{}, element pathElement) (interface{}, bool) {
+	t, v := valueType(value)
+	if element.isIndex {
+		list, _ := v.([]interface{})
+		if t != "L" || element.index >= len(list) {
+			return nil, false
+		}
+		return list[element.index], true
+	}
+	m, _ := v.(map[string]interface{})
+	if t != "M" {
+		return nil, false
+	}
+	value, ok := m[element.name]
+	return value, ok
+}
+
+// set sets the value at the path in the item.  Every parent must exist.
+func (path documentPath) set(item dynamoItem, value interface{}) error {
+	if len(path) == 1 {
+		item[path[0].name] = value
+		return nil
+	}
+	parent, ok := path[:len(path)-1].get(item)
+	if !ok {
+		return fmt.Errorf("the document path %s is invalid for update", path)
+	}
+	last := path[len(path)-1]
+	t, v := valueType(parent)
+	switch {
+	case last.isIndex && t == "L":
+		list, _ := v.([]interface{})
+		if last.index >= len(list) {
+			list = append(list, value)
+		} else {
+			list[last.index] = value
+		}
+		parent.(map[string]interface{})["L"] = list
+	case !last.isIndex && t == "M":
+		v.(map[string]interface{})[last.name] = value
+	default:
+		return fmt.Errorf("the document path %s is invalid for update", path)
+	}
+	return nil
+}
+
+// remove removes the value at the path in the item.
+func (path documentPath) remove(item dynamoItem) {
+	if len(path) == 1 {
+		delete(item, path[0].name)
+		return
+	}
+	parent, ok := path[:len(path)-1].get(item)
+	if !ok {
+		return
+	}
+	last := path[len(path)-1]
+	t, v := valueType(parent)
+	if last.isIndex && t == "L" {
+		list, _ := v.([]interface{})
+		if last.index < len(list) {
+			parent.(map[string]interface{})["L"] = append(list[:last.index], list[last.index+1:]...)
+		}
+	} else if m, ok := v.(map[string]interface{}); ok && t == "M" {
+		delete(m, last.name)
+	}
+}
+
+// condition evaluates a condition expression against an item.
+type condition func(dynamoItem) bool
+
+// operand evaluates an operand of an expression against an item.
+type operand func(dynamoItem) (interface{}, bool)
+
+// updateAction applies one action of an update expression.  Operands are
+// evaluated against the original item, and the result is written to item.
+type updateAction func(original, item dynamoItem) error
+
+// expressionParser parses DynamoDB condition, key condition, update and
+// projection expressions.
+type expressionParser struct {
+	tokens []string
+	pos    int
+	names  map[string]string
+	values map[string]interface{}
+	// used are the attribute names and values referenced by the expression.
+	used map[string]bool
+}
+
+func newExpressionParser(expression string, names map[string]string, values map[string]interface{}) (*expressionParser, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &expressionParser{tokens: tokens, names: names, values: values, used: map[string]bool{}}, nil
+}
+
+// tokenize splits an expression into names, values, numbers and operators.
+func tokenize(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == ':' || c == '_' || isAlphanumeric(c):
+			j := i + 1
+			for j < len(expression) && (expression[j] == '_' || isAlphanumeric(expression[j])) {
+				j++
+			}
+			tokens = append(tokens, expression[i:j])
+			i = j
+		case strings.HasPrefix(expression[i:], "<>") || strings.HasPrefix(expression[i:], "<=") ||
+			strings.HasPrefix(expression[i:], ">="):
+			tokens = append(tokens, expression[i:i+2])
+			i += 2
+		case strings.ContainsRune("=<>(),.[]+-", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q in expression %q", c, expression)
+		}
+	}
+	return tokens, nil
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *expressionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *expressionParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *expressionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *expressionParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q but found %q", token, got)
+	}
+	return nil
+}
+
+// keyword consumes the next token when it is the given keyword.
+func (p *expressionParser) keyword(keyword string) bool {
+	if strings.EqualFold(p.peek(), keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// isFunction returns true when the next tokens call the named function.
+func (p *expressionParser) isFunction(name string) bool {
+	return strings.EqualFold(p.peek(), name) && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "("
+}
+
+// name resolves an attribute name or #name placeholder.
+func (p *expressionParser) name(token string) (string, error) {
+	if strings.HasPrefix(token, "#") {
+		name, ok := p.names[token]
+		if !ok {
+			return "", fmt.Errorf("an expression attribute name used in the document path is not defined; attribute name: %s", token)
+		}
+		p.used[token] = true
+		return name, nil
+	}
+	if token == "" || !(token[0] == '_' || isAlphanumeric(token[0])) {
+		return "", fmt.Errorf("invalid attribute name %q", token)
+	}
+	return token, nil
+}
+
+func (p *expressionParser) path() (documentPath, error) {
+	first, err := p.name(p.next())
+	if err != nil {
+		return nil, err
+	}
+	path := documentPath{{name: first}}
+	for {
+		switch p.peek() {
+		case ".":
+			p.next()
+			name, err := p.name(p.next())
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, pathElement{name: name})
+		case "[":
+			p.next()
+			index, err := strconv.Atoi(p.next())
+			if err != nil {
+				return nil, fmt.Errorf("invalid list index in %s", path)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, pathElement{index: index, isIndex: true})
+		default:
+			return path, nil
+		}
+	}
+}
+
+// value resolves a :value placeholder.
+func (p *expressionParser) value(token string) (interface{}, error) {
+	value, ok := p.values[token]
+	if !ok {
+		return nil, fmt.Errorf("an expression attribute value used in expression is not defined; attribute value: %s", token)
+	}
+	p.used[token] = true
+	return value, nil
+}
+
+func (p *expressionParser) operand() (operand, error) {
+	if strings.HasPrefix(p.peek(), ":") {
+		value, err := p.value(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return func(dynamoItem) (interface{}, bool) { return value, true }, nil
+	}
+	if p.isFunction("size") {
+		p.pos += 2
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item dynamoItem) (interface{}, bool) {
+			value, ok := path.get(item)
+			if !ok {
+				return nil, false
+			}
+			return sizeOf(value)
+		}, nil
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	return func(item dynamoItem) (interface{}, bool) { return path.get(item) }, nil
+}
+
+// sizeOf implements the size function.
+func sizeOf(value interface{}) (interface{}, bool) {
+	t, v := valueType(value)
+	size := 0
+	switch t {
+	case "S":
+		s, _ := v.(string)
+		size = len(s)
+	case "B":
+		s, _ := v.(string)
+		b, _ := base64.StdEncoding.DecodeString(s)
+		size = len(b)
+	case "SS", "NS", "BS", "L":
+		list, _ := v.([]interface{})
+		size = len(list)
+	case "M":
+		m, _ := v.(map[string]interface{})
+		size = len(m)
+	default:
+		return nil, false
+	}
+	return map[string]interface{}{"N": strconv.Itoa(size)}, true
+}
+
+// parseCondition parses a whole condition expression.
+func parseCondition(expression string, names map[string]string, values map[string]interface{}) (condition, *expressionParser, error) {
+	p, err := newExpressionParser(expression, names, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := p.or()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid expression %q: %s", expression, err)
+	}
+	if !p.done() {
+		return nil, nil, fmt.Errorf("invalid expression %q: unexpected %q", expression, p.peek())
+	}
+	return c, p, nil
+}
+
+func (p *expressionParser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item dynamoItem) bool { return l(item) || right(item) }
+	}
+	return left, nil
+}
+
+func (p *expressionParser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item dynamoItem) bool { return l(item) && right(item) }
+	}
+	return left, nil
+}
+
+func (p *expressionParser) not() (condition, error) {
+	if p.keyword("NOT") {
+		c, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(item dynamoItem) bool { return !c(item) }, nil
+	}
+	return p.primary()
+}
+
+func (p *expressionParser) primary() (condition, error) {
+	if p.peek() == "(" {
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+	for _, function := range []string{"attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains"} {
+		if p.isFunction(function) {
+			return p.function(function)
+		}
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("BETWEEN") {
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, errors.New("expected AND in BETWEEN")
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(item dynamoItem) bool {
+			value, okV := left(item)
+			l, okL := low(item)
+			h, okH := high(item)
+			if !okV || !okL || !okH {
+				return false
+			}
+			cl, okL := compareValues(value, l)
+			ch, okH := compareValues(value, h)
+			return okL && okH && cl >= 0 && ch <= 0
+		}, nil
+	}
+	if p.keyword("IN") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			o, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item dynamoItem) bool {
+			value, ok := left(item)
+			if !ok {
+				return false
+			}
+			for _, o := range list {
+				if candidate, ok := o(item); ok && equalValues(value, candidate) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	comparator := p.next()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison(comparator, left, right)
+}
+
+func comparison(comparator string, left, right operand) (condition, error) {
+	switch comparator {
+	case "=", "<>":
+		equal := comparator == "="
+		return func(item dynamoItem) bool {
+			l, okL := left(item)
+			r, okR := right(item)
+			if !okL || !okR {
+				return !equal
+			}
+			return equalValues(l, r) == equal
+		}, nil
+	case "<", "<=", ">", ">=":
+		return func(item dynamoItem) bool {
+			l, okL := left(item)
+			r, okR := right(item)
+			if !okL || !okR {
+				return false
+			}
+			c, ok := compareValues(l, r)
+			if !ok {
+				return false
+			}
+			switch comparator {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			default:
+				return c >= 0
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", comparator)
+}
+
+func (p *expressionParser) function(name string) (condition, error) {
+	p.pos += 2
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	var argument operand
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if argument, err = p.operand(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return func(item dynamoItem) bool {
+		value, ok := path.get(item)
+		switch name {
+		case "attribute_exists":
+			return ok
+		case "attribute_not_exists":
+			return !ok
+		}
+		arg, okArg := argument(item)
+		if !ok || !okArg {
+			return false
+		}
+		t, v := valueType(value)
+		at, av := valueType(arg)
+		switch name {
+		case "attribute_type":
+			return av == t
+		case "begins_with":
+			s, _ := v.(string)
+			prefix, _ := av.(string)
+			if t == "B" && at == "B" {
+				b, _ := base64.StdEncoding.DecodeString(s)
+				bp, _ := base64.StdEncoding.DecodeString(prefix)
+				return bytes.HasPrefix(b, bp)
+			}
+			return t == "S" && at == "S" && strings.HasPrefix(s, prefix)
+		default: // contains
+			if t == "S" && at == "S" {
+				s, _ := v.(string)
+				sub, _ := av.(string)
+				return strings.Contains(s, sub)
+			}
+			members, _ := v.([]interface{})
+			for _, member := range members {
+				switch t {
+				case "L":
+					if equalValues(member, arg) {
+						return true
+					}
+				case "SS", "NS", "BS":
+					if equalValues(map[string]interface{}{t[:1]: member}, arg) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+	}, nil
+}
+
+// parseUpdate parses an update expression.  The names of the top level
+// attributes it updates are returned with the actions.
+func parseUpdate(expression string, names map[string]string, values map[string]interface{}) ([]updateAction, []string, *expressionParser, error) {
+	p, err := newExpressionParser(expression, names, values)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var actions []updateAction
+	var updated []string
+	for !p.done() {
+		clause := strings.ToUpper(p.next())
+		for {
+			path, err := p.path()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("invalid update expression %q: %s", expression, err)
+			}
+			updated = append(updated, path[0].name)
+			action, err := p.updateAction(clause, path)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("invalid update expression %q: %s", expression, err)
+			}
+			actions = append(actions, action)
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+	}
+	return actions, updated, p, nil
+}
+
+func (p *expressionParser) updateAction(clause string, path documentPath) (updateAction, error) {
+	switch clause {
+	case "SET":
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.setValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(original, item dynamoItem) error {
+			v, ok := value(original)
+			if !ok {
+				return fmt.Errorf("the provided expression refers to an attribute that does not exist in the item")
+			}
+			return path.set(item, v)
+		}, nil
+	case "REMOVE":
+		return func(_, item dynamoItem) error {
+			path.remove(item)
+			return nil
+		}, nil
+	case "ADD", "DELETE":
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(original, item dynamoItem) error {
+			v, _ := value(original)
+			current, exists := path.get(original)
+			result, err := addOrDelete(clause, current, exists, v)
+			if err != nil {
+				return err
+			}
+			return path.set(item, result)
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", clause)
+}
+
+// setValue parses the value of a SET action.
+func (p *expressionParser) setValue() (operand, error) {
+	left, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "+" && p.peek() != "-" {
+		return left, nil
+	}
+	sign := p.next()
+	right, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(item dynamoItem) (interface{}, bool) {
+		l, okL := left(item)
+		r, okR := right(item)
+		a, okA := valueNumber(l)
+		b, okB := valueNumber(r)
+		if !okL || !okR || !okA || !okB {
+			return nil, false
+		}
+		if sign == "+" {
+			return map[string]interface{}{"N": formatNumber(new(big.Rat).Add(a, b))}, true
+		}
+		return map[string]interface{}{"N": formatNumber(new(big.Rat).Sub(a, b))}, true
+	}, nil
+}
+
+func (p *expressionParser) setOperand() (operand, error) {
+	switch {
+	case p.isFunction("if_not_exists"):
+		p.pos += 2
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item dynamoItem) (interface{}, bool) {
+			if value, ok := path.get(item); ok {
+				return value, true
+			}
+			return fallback(item)
+		}, nil
+	case p.isFunction("list_append"):
+		p.pos += 2
+		first, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		second, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item dynamoItem) (interface{}, bool) {
+			a, okA := first(item)
+			b, okB := second(item)
+			ta, la := valueType(a)
+			tb, lb := valueType(b)
+			if !okA || !okB || ta != "L" || tb != "L" {
+				return nil, false
+			}
+			listA, _ := la.([]interface{})
+			listB, _ := lb.([]interface{})
+			return map[string]interface{}{"L": append(append([]interface{}{}, listA...), listB...)}, true
+		}, nil
+	}
+	return p.operand()
+}
+
+// addOrDelete implements the ADD and DELETE update actions.
+func addOrDelete(clause string, current interface{}, exists bool, value interface{}) (interface{}, error) {
+	t, v := valueType(value)
+	if clause == "ADD" && t == "N" {
+		total, _ := valueNumber(value)
+		if exists {
+			n, ok := valueNumber(current)
+			if !ok {
+				return nil, errors.New("an operand in the update expression has an incorrect data type")
+			}
+			total = new(big.Rat).Add(total, n)
+		}
+		return map[string]interface{}{"N": formatNumber(total)}, nil
+	}
+	if t != "SS" && t != "NS" && t != "BS" {
+		return nil, errors.New("an operand in the update expression has an incorrect data type")
+	}
+
+	var members []interface{}
+	if exists {
+		ct, cv := valueType(current)
+		if ct != t {
+			return nil, errors.New("an operand in the update expression has an incorrect data type")
+		}
+		members, _ = cv.([]interface{})
+	}
+	changes, _ := v.([]interface{})
+	result := []interface{}{}
+	for _, member := range members {
+		if clause == "ADD" || !containsMember(changes, member) {
+			result = append(result, member)
+		}
+	}
+	if clause == "ADD" {
+		for _, change := range changes {
+			if !containsMember(result, change) {
+				result = append(result, change)
+			}
+		}
+	}
+	return map[string]interface{}{t: result}, nil
+}
+
+func containsMember(members []interface{}, member interface{}) bool {
+	for _, m := range members {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProjection parses a projection expression into the paths it names.
+func parseProjection(expression string, names map[string]string) ([]documentPath, *expressionParser, error) {
+	p, err := newExpressionParser(expression, names, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var paths []documentPath
+	for !p.done() {
+		path, err := p.path()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid projection expression %q: %s", expression, err)
+		}
+		paths = append(paths, path)
+		if !p.done() {
+			if err := p.expect(","); err != nil {
+				return nil, nil, fmt.Errorf("invalid projection expression %q: %s", expression, err)
+			}
+		}
+	}
+	return paths, p, nil
+}
+
+// project returns the attributes of the item named by the paths.  Nested paths
+// return the whole top level attribute.
+func project(item dynamoItem, paths []documentPath) dynamoItem {
+	if item == nil || len(paths) == 0 {
+		return item
+	}
+	projected := dynamoItem{}
+	for _, path := range paths {
+		if value, ok := item[path[0].name]; ok {
+			projected[path[0].name] = value
+		}
+	}
+	return projected
+}
@@ -0,0 +1,388 @@
+package localstack
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3Namespace is the XML namespace of S3 responses.
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// s3TimeFormat is the format of the timestamps in S3 XML responses.
+const s3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+// memoryS3 holds the buckets of the in-process backend.
+type memoryS3 struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	created time.Time
+	objects map[string]*memoryObject
+}
+
+type memoryObject struct {
+	body        []byte
+	contentType string
+	metadata    map[string]string
+	etag        string
+	modified    time.Time
+}
+
+func newMemoryS3() *memoryS3 {
+	return &memoryS3{buckets: map[string]*memoryBucket{}}
+}
+
+// serve handles the path style S3 call described by call.
+func (s *memoryS3) serve(w http.ResponseWriter, r *http.Request, call *Call, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, _ := call.Params["Bucket"].(string)
+	key, _ := call.Params["Key"].(string)
+
+	switch call.Operation {
+	case "ListBuckets":
+		s.listBuckets(w)
+	case "CreateBucket":
+		s.createBucket(w, call, bucket)
+	case "HeadBucket":
+		if s.bucket(w, call, bucket) != nil {
+			w.WriteHeader(http.StatusOK)
+		}
+	case "DeleteBucket":
+		s.deleteBucket(w, call, bucket)
+	case "ListObjects", "ListObjectsV2":
+		if b := s.bucket(w, call, bucket); b != nil {
+			listObjects(w, r.URL.Query(), bucket, b, call.Operation == "ListObjectsV2")
+		}
+	case "PutObject":
+		if b := s.bucket(w, call, bucket); b != nil {
+			if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+				s.copyObject(w, r, call, b, key, source)
+				return
+			}
+			object := newMemoryObject(body, r.Header)
+			b.objects[key] = object
+			w.Header().Set("ETag", object.etag)
+			w.WriteHeader(http.StatusOK)
+		}
+	case "GetObject", "HeadObject":
+		if b := s.bucket(w, call, bucket); b != nil {
+			object, ok := b.objects[key]
+			if !ok {
+				writeAWSError(w, call, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+				return
+			}
+			object.write(w, r, call.Operation == "GetObject")
+		}
+	case "DeleteObject":
+		if b := s.bucket(w, call, bucket); b != nil {
+			delete(b.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		unsupportedOperation(w, call)
+	}
+}
+
+// bucket returns the named bucket, or writes a NoSuchBucket error and returns nil.
+func (s *memoryS3) bucket(w http.ResponseWriter, call *Call, name string) *memoryBucket {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeAWSError(w, call, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	return b
+}
+
+func (s *memoryS3) listBuckets(w http.ResponseWriter) {
+	type bucket struct {
+		Name         string
+		CreationDate string
+	}
+	result := struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Owner   struct {
+			ID          string
+			DisplayName string
+		}
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}{Xmlns: s3Namespace}
+	result.Owner.ID = memoryAccountID
+	result.Owner.DisplayName = "localstack"
+
+	names := make([]string, 0, len(s.buckets))
+	for name := range s.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result.Buckets = append(result.Buckets, bucket{
+			Name:         name,
+			CreationDate: s.buckets[name].created.Format(s3TimeFormat),
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *memoryS3) createBucket(w http.ResponseWriter, call *Call, name string) {
+	if _, ok := s.buckets[name]; ok {
+		writeAWSError(w, call, http.StatusConflict, "BucketAlreadyOwnedByYou",
+			"Your previous request to create the named bucket succeeded and you already own it.")
+		return
+	}
+	s.buckets[name] = &memoryBucket{created: time.Now().UTC(), objects: map[string]*memoryObject{}}
+	w.Header().Set("Location", "/"+name)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *memoryS3) deleteBucket(w http.ResponseWriter, call *Call, name string) {
+	b := s.bucket(w, call, name)
+	if b == nil {
+		return
+	}
+	if len(b.objects) > 0 {
+		writeAWSError(w, call, http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+		return
+	}
+	delete(s.buckets, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// copyObject copies the object named by the X-Amz-Copy-Source header.
+func (s *memoryS3) copyObject(w http.ResponseWriter, r *http.Request, call *Call, b *memoryBucket, key, source string) {
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	from := s.bucket(w, call, parts[0])
+	if from == nil {
+		return
+	}
+	var original *memoryObject
+	if len(parts) == 2 {
+		original = from.objects[parts[1]]
+	}
+	if original == nil {
+		writeAWSError(w, call, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	object := newMemoryObject(original.body, r.Header)
+	if !strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		object.contentType = original.contentType
+		object.metadata = original.metadata
+	}
+	b.objects[key] = object
+
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: object.etag, LastModified: object.modified.Format(s3TimeFormat)})
+}
+
+// newMemoryObject creates an object from the body and headers of a PutObject.
+func newMemoryObject(body []byte, header http.Header) *memoryObject {
+	sum := md5.Sum(body) //nolint:gosec
+	object := &memoryObject{
+		body:        append([]byte(nil), body...),
+		contentType: header.Get("Content-Type"),
+		metadata:    map[string]string{},
+		etag:        fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:])),
+		modified:    time.Now().UTC(),
+	}
+	if object.contentType == "" {
+		object.contentType = "binary/octet-stream"
+	}
+	for name, values := range header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			object.metadata[name] = values[0]
+		}
+	}
+	return object
+}
+
+// write writes the object's headers and, when withBody is true, its contents.
+// A single "bytes=first-last" range is honoured.
+func (object *memoryObject) write(w http.ResponseWriter, r *http.Request, withBody bool) {
+	w.Header().Set("Content-Type", object.contentType)
+	w.Header().Set("ETag", object.etag)
+	w.Header().Set("Last-Modified", object.modified.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+	for name, value := range object.metadata {
+		w.Header().Set(name, value)
+	}
+
+	contents, status := object.body, http.StatusOK
+	if first, last, ok := parseRange(r.Header.Get("Range"), len(object.body)); ok {
+		contents, status = object.body[first:last+1], http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(object.body)))
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+	w.WriteHeader(status)
+	if withBody {
+		w.Write(contents) //nolint:errcheck
+	}
+}
+
+// parseRange parses a single "bytes=first-last" range of an object of the given size.
+func parseRange(header string, size int) (int, int, bool) {
+	if !strings.HasPrefix(header, "bytes=") || size == 0 {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	first, last := 0, size-1
+	var err error
+	switch {
+	case bounds[0] == "":
+		// A suffix range. (I.E. "bytes=-500")
+		var suffix int
+		if suffix, err = strconv.Atoi(bounds[1]); err != nil {
+			return 0, 0, false
+		}
+		if suffix < size {
+			first = size - suffix
+		}
+	case bounds[1] == "":
+		first, err = strconv.Atoi(bounds[0])
+	default:
+		if first, err = strconv.Atoi(bounds[0]); err == nil {
+			last, err = strconv.Atoi(bounds[1])
+		}
+	}
+	if err != nil || first > last || first >= size {
+		return 0, 0, false
+	}
+	if last >= size {
+		last = size - 1
+	}
+	return first, last, true
+}
+
+// listObjects writes a ListObjects, or ListObjectsV2, response for the bucket.
+func listObjects(w http.ResponseWriter, query url.Values, name string, b *memoryBucket, v2 bool) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+		StorageClass string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Xmlns                 string   `xml:"xmlns,attr"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		Marker                string `xml:",omitempty"`
+		NextMarker            string `xml:",omitempty"`
+		StartAfter            string `xml:",omitempty"`
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		KeyCount              *int   `xml:",omitempty"`
+		MaxKeys               int
+		IsTruncated           bool
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}{
+		Xmlns:     s3Namespace,
+		Name:      name,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		MaxKeys:   1000,
+	}
+	if maxKeys, err := strconv.Atoi(query.Get("max-keys")); err == nil && maxKeys >= 0 {
+		result.MaxKeys = maxKeys
+	}
+
+	after := query.Get("marker")
+	if v2 {
+		result.StartAfter = query.Get("start-after")
+		result.ContinuationToken = query.Get("continuation-token")
+		after = result.StartAfter
+		if result.ContinuationToken != "" {
+			after = result.ContinuationToken
+		}
+	} else {
+		result.Marker = after
+	}
+
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	seen := map[string]bool{}
+	count, last := 0, ""
+	for _, key := range keys {
+		if key <= after || !strings.HasPrefix(key, result.Prefix) {
+			continue
+		}
+		prefix := ""
+		if result.Delimiter != "" {
+			if i := strings.Index(key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				prefix = key[:len(result.Prefix)+i+len(result.Delimiter)]
+			}
+		}
+		if prefix != "" && (seen[prefix] || prefix <= after) {
+			continue
+		}
+		if count == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+		if prefix != "" {
+			seen[prefix] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: prefix})
+			last = prefix
+			continue
+		}
+		object := b.objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: object.modified.Format(s3TimeFormat),
+			ETag:         object.etag,
+			Size:         len(object.body),
+			StorageClass: "STANDARD",
+		})
+		last = key
+	}
+
+	if v2 {
+		result.KeyCount = &count
+		if result.IsTruncated {
+			result.NextContinuationToken = last
+		}
+	} else if result.IsTruncated {
+		result.NextMarker = last
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// writeXML writes an XML response.
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header)) //nolint:errcheck
+	xml.NewEncoder(w).Encode(v) //nolint:errcheck
+}
@@ -0,0 +1,139 @@
+package localstack
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// newMemoryLocalstack returns a Localstack instance backed by a new MemoryBackend.
+func newMemoryLocalstack(t *testing.T, names ...string) *Localstack {
+	services := &LocalstackServiceCollection{}
+	for _, name := range names {
+		service, err := NewLocalstackService(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*services = append(*services, *service)
+	}
+	ls, err := NewLocalstack(services, WithBackend(NewMemoryBackend()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ls
+}
+
+func Test_MemoryBackend_S3(t *testing.T) {
+	ls := newMemoryLocalstack(t, "s3")
+	defer ls.Destroy()
+	svc, err := ls.S3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("examplebucket")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a/1.txt", "a/2.txt", "b/1.txt", "c.txt"} {
+		_, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket:      aws.String("examplebucket"),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader([]byte("hello " + key)),
+			ContentType: aws.String("text/plain"),
+			Metadata:    map[string]*string{"Owner": aws.String("tests")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	object, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("examplebucket"), Key: aws.String("a/1.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, _ := ioutil.ReadAll(object.Body)
+	if string(contents) != "hello a/1.txt" || aws.StringValue(object.ContentType) != "text/plain" ||
+		aws.StringValue(object.Metadata["Owner"]) != "tests" {
+		t.Errorf("The object was not stored correctly.  Received %q %v", contents, object)
+	}
+
+	partial, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("examplebucket"), Key: aws.String("a/1.txt"), Range: aws.String("bytes=0-4"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, _ = ioutil.ReadAll(partial.Body)
+	if string(contents) != "hello" {
+		t.Errorf("We were expecting the first five bytes.  Received %q", contents)
+	}
+
+	listed, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("examplebucket"), Delimiter: aws.String("/")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Contents) != 1 || len(listed.CommonPrefixes) != 2 {
+		t.Errorf("We were expecting c.txt and the a/ and b/ prefixes.  Received %v", listed)
+	}
+
+	var keys []string
+	err = svc.ListObjectsPages(&s3.ListObjectsInput{Bucket: aws.String("examplebucket"), MaxKeys: aws.Int64(1)},
+		func(page *s3.ListObjectsOutput, _ bool) bool {
+			for _, object := range page.Contents {
+				keys = append(keys, aws.StringValue(object.Key))
+			}
+			return true
+		})
+	if err != nil || len(keys) != 4 {
+		t.Errorf("We were expecting every key over four pages.  Received %v %v", keys, err)
+	}
+
+	if _, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket: aws.String("examplebucket"), Key: aws.String("copy.txt"), CopySource: aws.String("examplebucket/c.txt"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("examplebucket"), Key: aws.String("copy.txt")}); err != nil {
+		t.Errorf("The copy should exist.  Received %v", err)
+	}
+
+	_, err = svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("examplebucket"), Key: aws.String("missing")})
+	if awsErrorCode(err) != s3.ErrCodeNoSuchKey {
+		t.Errorf("We were expecting NoSuchKey.  Received %v", err)
+	}
+	_, err = svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("examplebucket")})
+	if awsErrorCode(err) != "BucketNotEmpty" {
+		t.Errorf("We were expecting BucketNotEmpty.  Received %v", err)
+	}
+}
+
+func Test_MemoryBackend_EndpointBeforeStart(t *testing.T) {
+	if endpoint := NewMemoryBackend().Endpoint(); endpoint != "" {
+		t.Errorf("We were expecting no endpoint before the backend is started.  Received %s", endpoint)
+	}
+}
+
+func Test_MemoryBackend_Unsupported(t *testing.T) {
+	lambda, _ := NewLocalstackService("lambda")
+	if _, err := NewLocalstack(&LocalstackServiceCollection{*lambda}, WithBackend(NewMemoryBackend())); err == nil {
+		t.Error("We were expecting lambda to be rejected by the in-memory backend.")
+	}
+
+	os.Setenv(BackendEnv, "memory")
+	defer os.Unsetenv(BackendEnv)
+	s3, _ := NewLocalstackService("s3")
+	ls, err := NewLocalstack(&LocalstackServiceCollection{*s3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ls.backend.(*MemoryBackend); !ok {
+		t.Errorf("The environment should choose the in-memory backend.  Received %T", ls.backend)
+	}
+	if err := ls.Destroy(); err != nil {
+		t.Error(err)
+	}
+}
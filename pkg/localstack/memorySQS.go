@@ -0,0 +1,528 @@
+package localstack
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqsNamespace is the XML namespace of SQS responses.
+const sqsNamespace = "http://queue.amazonaws.com/doc/2012-11-05/"
+
+// sqsPollInterval is how often a long poll checks for new messages.
+const sqsPollInterval = 20 * time.Millisecond
+
+// memorySQS holds the queues of the in-process backend.
+type memorySQS struct {
+	mu       sync.Mutex
+	queues   map[string]*memoryQueue
+	sequence int
+}
+
+type memoryQueue struct {
+	name       string
+	created    time.Time
+	attributes map[string]string
+	messages   []*memoryMessage
+}
+
+type memoryMessage struct {
+	id            string
+	body          string
+	attributes    []sqsMessageAttribute
+	sent          time.Time
+	visibleAt     time.Time
+	receiptHandle string
+	receiveCount  int
+}
+
+// sqsMessageAttribute is a message attribute of an SQS message.
+type sqsMessageAttribute struct {
+	Name  string
+	Value struct {
+		DataType    string
+		StringValue string `xml:",omitempty"`
+		BinaryValue string `xml:",omitempty"`
+	}
+}
+
+func newMemorySQS() *memorySQS {
+	return &memorySQS{queues: map[string]*memoryQueue{}}
+}
+
+// serve handles the query protocol SQS call described by call.
+func (s *memorySQS) serve(w http.ResponseWriter, r *http.Request, call *Call, body []byte) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeAWSError(w, call, http.StatusBadRequest, "MalformedQueryString", err.Error())
+		return
+	}
+
+	if call.Operation == "ReceiveMessage" {
+		s.receiveMessage(w, r, call, values)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch call.Operation {
+	case "CreateQueue":
+		s.createQueue(w, r, call, values)
+	case "GetQueueUrl":
+		if queue := s.queueNamed(w, call, values.Get("QueueName")); queue != nil {
+			writeSQSResult(w, call.Operation, struct {
+				QueueUrl string //nolint:golint
+			}{queueURL(r, queue.name)})
+		}
+	case "ListQueues":
+		var urls []string
+		for name := range s.queues {
+			if strings.HasPrefix(name, values.Get("QueueNamePrefix")) {
+				urls = append(urls, queueURL(r, name))
+			}
+		}
+		sort.Strings(urls)
+		writeSQSResult(w, call.Operation, struct {
+			QueueUrl []string //nolint:golint
+		}{urls})
+	case "DeleteQueue":
+		if queue := s.queue(w, call, values); queue != nil {
+			delete(s.queues, queue.name)
+			writeSQSResult(w, call.Operation, nil)
+		}
+	case "PurgeQueue":
+		if queue := s.queue(w, call, values); queue != nil {
+			queue.messages = nil
+			writeSQSResult(w, call.Operation, nil)
+		}
+	case "GetQueueAttributes":
+		if queue := s.queue(w, call, values); queue != nil {
+			s.getQueueAttributes(w, call, queue, indexedValues(values, "AttributeName"))
+		}
+	case "SetQueueAttributes":
+		if queue := s.queue(w, call, values); queue != nil {
+			for name, value := range queueAttributes(values) {
+				queue.attributes[name] = value
+			}
+			writeSQSResult(w, call.Operation, nil)
+		}
+	case "SendMessage":
+		if queue := s.queue(w, call, values); queue != nil {
+			writeSQSResult(w, call.Operation, s.sendMessage(queue, values, ""))
+		}
+	case "SendMessageBatch":
+		if queue := s.queue(w, call, values); queue != nil {
+			s.sendMessageBatch(w, call, queue, values)
+		}
+	case "DeleteMessage":
+		if queue := s.queue(w, call, values); queue != nil {
+			queue.deleteMessage(values.Get("ReceiptHandle"))
+			writeSQSResult(w, call.Operation, nil)
+		}
+	case "DeleteMessageBatch":
+		if queue := s.queue(w, call, values); queue != nil {
+			s.deleteMessageBatch(w, call, queue, values)
+		}
+	case "ChangeMessageVisibility":
+		if queue := s.queue(w, call, values); queue != nil {
+			s.changeMessageVisibility(w, call, queue, values)
+		}
+	default:
+		unsupportedOperation(w, call)
+	}
+}
+
+// queueURL returns the URL of the named queue on the host the request was sent to.
+func queueURL(r *http.Request, name string) string {
+	return fmt.Sprintf("http://%s/%s/%s", r.Host, memoryAccountID, name)
+}
+
+// queue returns the queue named by the QueueUrl parameter, or writes a
+// NonExistentQueue error and returns nil.
+func (s *memorySQS) queue(w http.ResponseWriter, call *Call, values url.Values) *memoryQueue {
+	queueURL := values.Get("QueueUrl")
+	return s.queueNamed(w, call, queueURL[strings.LastIndex(queueURL, "/")+1:])
+}
+
+func (s *memorySQS) queueNamed(w http.ResponseWriter, call *Call, name string) *memoryQueue {
+	queue, ok := s.queues[name]
+	if !ok {
+		writeAWSError(w, call, http.StatusBadRequest, "AWS.SimpleQueueService.NonExistentQueue",
+			"The specified queue does not exist for this wsdl version.")
+	}
+	return queue
+}
+
+func (s *memorySQS) createQueue(w http.ResponseWriter, r *http.Request, call *Call, values url.Values) {
+	name := values.Get("QueueName")
+	attributes := queueAttributes(values)
+	if queue, ok := s.queues[name]; ok {
+		for key, value := range attributes {
+			if queue.attributes[key] != value {
+				writeAWSError(w, call, http.StatusBadRequest, "QueueAlreadyExists",
+					fmt.Sprintf("A queue named %s already exists with different attributes", name))
+				return
+			}
+		}
+	} else {
+		if name == "" {
+			writeAWSError(w, call, http.StatusBadRequest, "MissingParameter", "QueueName is required")
+			return
+		}
+		queue := &memoryQueue{
+			name:       name,
+			created:    time.Now(),
+			attributes: map[string]string{"VisibilityTimeout": "30", "DelaySeconds": "0"},
+		}
+		for key, value := range attributes {
+			queue.attributes[key] = value
+		}
+		s.queues[name] = queue
+	}
+
+	writeSQSResult(w, call.Operation, struct {
+		QueueUrl string //nolint:golint
+	}{queueURL(r, name)})
+}
+
+func (s *memorySQS) getQueueAttributes(w http.ResponseWriter, call *Call, queue *memoryQueue, names []string) {
+	now := time.Now()
+	visible, hidden := 0, 0
+	for _, message := range queue.messages {
+		if message.visibleAt.After(now) {
+			hidden++
+		} else {
+			visible++
+		}
+	}
+	all := map[string]string{
+		"QueueArn":                              fmt.Sprintf("arn:aws:sqs:%s:%s:%s", memoryRegion, memoryAccountID, queue.name),
+		"CreatedTimestamp":                      strconv.FormatInt(queue.created.Unix(), 10),
+		"ApproximateNumberOfMessages":           strconv.Itoa(visible),
+		"ApproximateNumberOfMessagesNotVisible": strconv.Itoa(hidden),
+	}
+	for name, value := range queue.attributes {
+		all[name] = value
+	}
+
+	type attribute struct {
+		Name  string
+		Value string
+	}
+	var attributes []attribute
+	for _, name := range sortedKeys(all) {
+		if containsName(names, name) {
+			attributes = append(attributes, attribute{name, all[name]})
+		}
+	}
+	writeSQSResult(w, call.Operation, struct {
+		Attribute []attribute
+	}{attributes})
+}
+
+type sendMessageResult struct {
+	MessageId              string //nolint:golint
+	MD5OfMessageBody       string
+	MD5OfMessageAttributes string `xml:",omitempty"`
+}
+
+// sendMessage adds a message to the queue from the parameters with the given prefix.
+func (s *memorySQS) sendMessage(queue *memoryQueue, values url.Values, prefix string) sendMessageResult {
+	s.sequence++
+	now := time.Now()
+	message := &memoryMessage{
+		id:         fmt.Sprintf("%08d-0000-4000-8000-%012d", s.sequence, s.sequence),
+		body:       values.Get(prefix + "MessageBody"),
+		attributes: messageAttributes(values, prefix+"MessageAttribute"),
+		sent:       now,
+		visibleAt:  now,
+	}
+	delay := queue.attributes["DelaySeconds"]
+	if value := values.Get(prefix + "DelaySeconds"); value != "" {
+		delay = value
+	}
+	if seconds, err := strconv.Atoi(delay); err == nil {
+		message.visibleAt = now.Add(time.Duration(seconds) * time.Second)
+	}
+	queue.messages = append(queue.messages, message)
+
+	return sendMessageResult{
+		MessageId:              message.id,
+		MD5OfMessageBody:       md5Hex([]byte(message.body)),
+		MD5OfMessageAttributes: md5OfMessageAttributes(message.attributes),
+	}
+}
+
+func (s *memorySQS) sendMessageBatch(w http.ResponseWriter, call *Call, queue *memoryQueue, values url.Values) {
+	type entry struct {
+		Id string //nolint:golint
+		sendMessageResult
+	}
+	var entries []entry
+	for i := 1; values.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i)) != ""; i++ {
+		prefix := fmt.Sprintf("SendMessageBatchRequestEntry.%d.", i)
+		entries = append(entries, entry{values.Get(prefix + "Id"), s.sendMessage(queue, values, prefix)})
+	}
+	writeSQSResult(w, call.Operation, struct {
+		SendMessageBatchResultEntry []entry
+	}{entries})
+}
+
+func (s *memorySQS) deleteMessageBatch(w http.ResponseWriter, call *Call, queue *memoryQueue, values url.Values) {
+	type entry struct {
+		Id string //nolint:golint
+	}
+	var entries []entry
+	for i := 1; values.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.Id", i)) != ""; i++ {
+		prefix := fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.", i)
+		queue.deleteMessage(values.Get(prefix + "ReceiptHandle"))
+		entries = append(entries, entry{values.Get(prefix + "Id")})
+	}
+	writeSQSResult(w, call.Operation, struct {
+		DeleteMessageBatchResultEntry []entry
+	}{entries})
+}
+
+func (s *memorySQS) changeMessageVisibility(w http.ResponseWriter, call *Call, queue *memoryQueue, values url.Values) {
+	seconds, err := strconv.Atoi(values.Get("VisibilityTimeout"))
+	if err != nil {
+		writeAWSError(w, call, http.StatusBadRequest, "InvalidParameterValue", "VisibilityTimeout must be a number")
+		return
+	}
+	for _, message := range queue.messages {
+		if message.receiptHandle != "" && message.receiptHandle == values.Get("ReceiptHandle") {
+			message.visibleAt = time.Now().Add(time.Duration(seconds) * time.Second)
+			writeSQSResult(w, call.Operation, nil)
+			return
+		}
+	}
+	writeAWSError(w, call, http.StatusBadRequest, "ReceiptHandleIsInvalid", "The receipt handle is not valid")
+}
+
+// receiveMessage receives messages, waiting up to WaitTimeSeconds for them to arrive.
+func (s *memorySQS) receiveMessage(w http.ResponseWriter, r *http.Request, call *Call, values url.Values) {
+	max := 1
+	if n, err := strconv.Atoi(values.Get("MaxNumberOfMessages")); err == nil && n > 0 {
+		max = n
+	}
+	wait, _ := strconv.Atoi(values.Get("WaitTimeSeconds"))
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+
+	for {
+		s.mu.Lock()
+		queue := s.queue(w, call, values)
+		if queue == nil {
+			s.mu.Unlock()
+			return
+		}
+		messages := queue.receive(max, values)
+		s.mu.Unlock()
+
+		if len(messages) > 0 || !time.Now().Before(deadline) {
+			writeSQSResult(w, call.Operation, struct {
+				Message []receivedMessage
+			}{messages})
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(sqsPollInterval):
+		}
+	}
+}
+
+type receivedMessage struct {
+	MessageId              string //nolint:golint
+	ReceiptHandle          string
+	MD5OfBody              string
+	Body                   string
+	MD5OfMessageAttributes string `xml:",omitempty"`
+	Attribute              []struct {
+		Name  string
+		Value string
+	}
+	MessageAttribute []sqsMessageAttribute
+}
+
+// receive hides and returns up to max visible messages.
+func (queue *memoryQueue) receive(max int, values url.Values) []receivedMessage {
+	timeout := queue.attributes["VisibilityTimeout"]
+	if value := values.Get("VisibilityTimeout"); value != "" {
+		timeout = value
+	}
+	seconds, _ := strconv.Atoi(timeout)
+	attributeNames := indexedValues(values, "AttributeName")
+	messageAttributeNames := indexedValues(values, "MessageAttributeName")
+
+	now := time.Now()
+	var messages []receivedMessage
+	for _, message := range queue.messages {
+		if len(messages) == max {
+			break
+		}
+		if message.visibleAt.After(now) {
+			continue
+		}
+		message.receiveCount++
+		message.visibleAt = now.Add(time.Duration(seconds) * time.Second)
+		message.receiptHandle = fmt.Sprintf("%s#%d", message.id, message.receiveCount)
+
+		received := receivedMessage{
+			MessageId:     message.id,
+			ReceiptHandle: message.receiptHandle,
+			MD5OfBody:     md5Hex([]byte(message.body)),
+			Body:          message.body,
+		}
+		system := map[string]string{
+			"SenderId":                memoryAccountID,
+			"SentTimestamp":           strconv.FormatInt(message.sent.UnixNano()/int64(time.Millisecond), 10),
+			"ApproximateReceiveCount": strconv.Itoa(message.receiveCount),
+		}
+		for _, name := range sortedKeys(system) {
+			if containsName(attributeNames, name) {
+				received.Attribute = append(received.Attribute, struct {
+					Name  string
+					Value string
+				}{name, system[name]})
+			}
+		}
+		for _, attribute := range message.attributes {
+			if containsName(messageAttributeNames, attribute.Name) {
+				received.MessageAttribute = append(received.MessageAttribute, attribute)
+			}
+		}
+		received.MD5OfMessageAttributes = md5OfMessageAttributes(received.MessageAttribute)
+		messages = append(messages, received)
+	}
+	return messages
+}
+
+// deleteMessage removes the message last received with the receipt handle.
+func (queue *memoryQueue) deleteMessage(receiptHandle string) {
+	for i, message := range queue.messages {
+		if message.receiptHandle != "" && message.receiptHandle == receiptHandle {
+			queue.messages = append(queue.messages[:i], queue.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+// writeSQSResult writes a query protocol response for the operation.  The
+// fields of result become the children of the <operation>Result element.
+func writeSQSResult(w http.ResponseWriter, operation string, result interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+
+	response := xml.StartElement{
+		Name: xml.Name{Local: operation + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: sqsNamespace}},
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.EncodeToken(response) //nolint:errcheck
+	if result != nil {
+		encoder.EncodeElement(result, xml.StartElement{Name: xml.Name{Local: operation + "Result"}}) //nolint:errcheck
+	}
+	encoder.EncodeElement(struct { //nolint:errcheck
+		RequestId string //nolint:golint
+	}{"go-localstack"}, xml.StartElement{Name: xml.Name{Local: "ResponseMetadata"}})
+	encoder.EncodeToken(response.End()) //nolint:errcheck
+	encoder.Flush()                     //nolint:errcheck
+}
+
+// indexedValues returns the values of a list parameter. (I.E. "AttributeName.1")
+func indexedValues(values url.Values, name string) []string {
+	var list []string
+	for i := 1; values.Get(fmt.Sprintf("%s.%d", name, i)) != ""; i++ {
+		list = append(list, values.Get(fmt.Sprintf("%s.%d", name, i)))
+	}
+	return list
+}
+
+// queueAttributes returns the Attribute.N.Name and Attribute.N.Value parameters.
+func queueAttributes(values url.Values) map[string]string {
+	attributes := map[string]string{}
+	for i := 1; values.Get(fmt.Sprintf("Attribute.%d.Name", i)) != ""; i++ {
+		attributes[values.Get(fmt.Sprintf("Attribute.%d.Name", i))] = values.Get(fmt.Sprintf("Attribute.%d.Value", i))
+	}
+	return attributes
+}
+
+// messageAttributes returns the message attributes with the given parameter prefix.
+func messageAttributes(values url.Values, prefix string) []sqsMessageAttribute {
+	var attributes []sqsMessageAttribute
+	for i := 1; values.Get(fmt.Sprintf("%s.%d.Name", prefix, i)) != ""; i++ {
+		p := fmt.Sprintf("%s.%d.", prefix, i)
+		var attribute sqsMessageAttribute
+		attribute.Name = values.Get(p + "Name")
+		attribute.Value.DataType = values.Get(p + "Value.DataType")
+		attribute.Value.StringValue = values.Get(p + "Value.StringValue")
+		attribute.Value.BinaryValue = values.Get(p + "Value.BinaryValue")
+		attributes = append(attributes, attribute)
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Name < attributes[j].Name })
+	return attributes
+}
+
+// containsName returns true when names requests the given name, either
+// directly or with "All" or ".*".
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name || n == "All" || n == ".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// md5OfMessageAttributes computes the MD5 digest of message attributes the way SQS does.
+func md5OfMessageAttributes(attributes []sqsMessageAttribute) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+	hash := md5.New() //nolint:gosec
+	write := func(b []byte) {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(b)))
+		hash.Write(length)
+		hash.Write(b)
+	}
+	for _, attribute := range attributes {
+		write([]byte(attribute.Name))
+		write([]byte(attribute.Value.DataType))
+		if attribute.Value.BinaryValue != "" {
+			binaryValue, _ := base64.StdEncoding.DecodeString(attribute.Value.BinaryValue)
+			hash.Write([]byte{2})
+			write(binaryValue)
+		} else {
+			hash.Write([]byte{1})
+			write([]byte(attribute.Value.StringValue))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b) //nolint:gosec
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
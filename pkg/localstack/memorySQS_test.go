@@ -0,0 +1,93 @@
+package localstack
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func Test_MemoryBackend_SQS(t *testing.T) {
+	ls := newMemoryLocalstack(t, "sqs")
+	defer ls.Destroy()
+	svc, err := ls.SQS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := svc.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  aws.String("jobs"),
+		Attributes: map[string]*string{"VisibilityTimeout": aws.String("60")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String("jobs")})
+	if err != nil || aws.StringValue(found.QueueUrl) != aws.StringValue(queue.QueueUrl) {
+		t.Errorf("We were expecting the url of the queue.  Received %v %v", found, err)
+	}
+
+	_, err = svc.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    queue.QueueUrl,
+		MessageBody: aws.String("first"),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"Kind": {DataType: aws.String("String"), StringValue: aws.String("job")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.SendMessageBatch(&sqs.SendMessageBatchInput{
+		QueueUrl: queue.QueueUrl,
+		Entries: []*sqs.SendMessageBatchRequestEntry{
+			{Id: aws.String("1"), MessageBody: aws.String("second")},
+			{Id: aws.String("2"), MessageBody: aws.String("third")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := svc.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:              queue.QueueUrl,
+		MaxNumberOfMessages:   aws.Int64(2),
+		MessageAttributeNames: aws.StringSlice([]string{"All"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Messages) != 2 || aws.StringValue(received.Messages[0].Body) != "first" ||
+		aws.StringValue(received.Messages[0].MessageAttributes["Kind"].StringValue) != "job" {
+		t.Fatalf("We were expecting the first two messages.  Received %v", received)
+	}
+
+	attributes, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       queue.QueueUrl,
+		AttributeNames: aws.StringSlice([]string{"All"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(attributes.Attributes["ApproximateNumberOfMessages"]) != "1" ||
+		aws.StringValue(attributes.Attributes["ApproximateNumberOfMessagesNotVisible"]) != "2" ||
+		aws.StringValue(attributes.Attributes["VisibilityTimeout"]) != "60" {
+		t.Errorf("The queue attributes are wrong.  Received %v", attributes.Attributes)
+	}
+
+	for _, message := range received.Messages {
+		if _, err := svc.DeleteMessage(&sqs.DeleteMessageInput{
+			QueueUrl: queue.QueueUrl, ReceiptHandle: message.ReceiptHandle,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	received, err = svc.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: queue.QueueUrl, WaitTimeSeconds: aws.Int64(1)})
+	if err != nil || len(received.Messages) != 1 || aws.StringValue(received.Messages[0].Body) != "third" {
+		t.Errorf("We were expecting the last message.  Received %v %v", received, err)
+	}
+
+	_, err = svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String("missing")})
+	if awsErrorCode(err) != sqs.ErrCodeQueueDoesNotExist {
+		t.Errorf("We were expecting a missing queue error.  Received %v", err)
+	}
+}
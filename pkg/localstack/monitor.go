@@ -0,0 +1,246 @@
+package localstack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// defaultMonitorInterval is how often the backend is checked when
+// WithAutoRestart is used without WithMonitor.
+const defaultMonitorInterval = time.Second
+
+// monitorLogLines is how many of the last log lines are kept when the
+// container exits.
+const monitorLogLines = 20
+
+// ErrContainerExited is matched by the errors returned once the Localstack
+// container has stopped unexpectedly.  See WithMonitor.
+var ErrContainerExited = errors.New("localstack container exited")
+
+// ContainerExitError describes a Localstack container that stopped while
+// tests were using it.
+type ContainerExitError struct {
+	// ExitCode is the exit code of the container.
+	ExitCode int
+	// OOMKilled is true when the container ran out of memory.
+	OOMKilled bool
+	// Reason is the error reported by Docker, or why the container couldn't
+	// be inspected.
+	Reason string
+	// Logs are the last lines written by the container.
+	Logs []string
+}
+
+func (e *ContainerExitError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrContainerExited.Error())
+	switch {
+	case e.OOMKilled:
+		b.WriteString(" (OOMKilled)")
+	case e.Reason != "":
+		fmt.Fprintf(&b, " (%s)", e.Reason)
+	default:
+		fmt.Fprintf(&b, " (exit code %d)", e.ExitCode)
+	}
+	if len(e.Logs) > 0 {
+		b.WriteString(", last log lines:\n")
+		b.WriteString(strings.Join(e.Logs, "\n"))
+	}
+	return b.String()
+}
+
+// Is makes the error match ErrContainerExited.
+func (e *ContainerExitError) Is(target error) bool {
+	return target == ErrContainerExited
+}
+
+// WithMonitor checks the backend every interval while the instance is in
+// use.  Once the container has stopped, calls made through the sessions of
+// the instance fail with a *ContainerExitError holding the exit code and the
+// last log lines, rather than with connection errors, and Err returns it.
+func WithMonitor(interval time.Duration) Option {
+	return func(o *options) {
+		o.monitorInterval = interval
+	}
+}
+
+// WithAutoRestart restarts the container up to maxRestarts times when it
+// stops unexpectedly.  It implies WithMonitor, checking every second unless
+// another interval is set.  The restarted container is published on the
+// same port but starts empty: anything created before is lost and the init
+// callbacks and seeds are not run again.
+func WithAutoRestart(maxRestarts int) Option {
+	return func(o *options) {
+		o.maxRestarts = maxRestarts
+		if o.monitorInterval == 0 {
+			o.monitorInterval = defaultMonitorInterval
+		}
+	}
+}
+
+// Err returns the error of a container that stopped unexpectedly, or nil
+// while it runs or when it isn't monitored.
+func (ls *Localstack) Err() error {
+	if ls.monitor == nil {
+		return nil
+	}
+	ls.monitor.mu.Lock()
+	defer ls.monitor.mu.Unlock()
+	return ls.monitor.err
+}
+
+// Restarts returns how many times WithAutoRestart tried to restart the
+// container.
+func (ls *Localstack) Restarts() int {
+	if ls.monitor == nil {
+		return 0
+	}
+	ls.monitor.mu.Lock()
+	defer ls.monitor.mu.Unlock()
+	return ls.monitor.restarts
+}
+
+// restarter is implemented by the backends that restart in a way that
+// keeps their endpoint.  Other backends are stopped and started again.
+type restarter interface {
+	restart(services *LocalstackServiceCollection) error
+}
+
+// monitor checks the backend of an instance in the background.
+type monitor struct {
+	ls          *Localstack
+	maxRestarts int
+
+	// mu guards err and restarts.
+	mu       sync.Mutex
+	err      error
+	restarts int
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startMonitor checks the backend every interval until Destroy.
+func (ls *Localstack) startMonitor(interval time.Duration, maxRestarts int) {
+	ls.monitor = &monitor{
+		ls:          ls,
+		maxRestarts: maxRestarts,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go ls.monitor.run(interval)
+}
+
+func (m *monitor) run(interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// close stops the monitor and waits for a check in progress.
+func (m *monitor) close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
+
+// check records why the backend stopped, and restarts it when allowed.  The
+// error is cleared once the backend runs again.
+func (m *monitor) check() {
+	backend := m.ls.backend
+	info, err := backend.Inspect()
+	if err == nil && info.Running {
+		m.setErr(nil)
+		return
+	}
+
+	m.mu.Lock()
+	known := m.err != nil
+	restart := m.restarts < m.maxRestarts
+	if restart {
+		m.restarts++
+	}
+	m.mu.Unlock()
+	if known && !restart {
+		return
+	}
+
+	exitErr := &ContainerExitError{Logs: lastLogLines(backend, monitorLogLines)}
+	if err != nil {
+		exitErr.Reason = err.Error()
+	} else {
+		exitErr.ExitCode = info.ExitCode
+		exitErr.OOMKilled = info.OOMKilled
+		exitErr.Reason = info.Error
+	}
+	m.setErr(exitErr)
+	if !restart {
+		return
+	}
+
+	if r, ok := backend.(restarter); ok {
+		err = r.restart(m.ls.Services)
+	} else if err = backend.Stop(); err == nil {
+		err = backend.Start(m.ls.Services)
+	}
+	if err != nil {
+		m.setErr(fmt.Errorf("%w (unable to restart it: %s)", exitErr, err))
+		return
+	}
+	m.setErr(nil)
+}
+
+func (m *monitor) setErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+// lastLogLines returns the last n lines written by the backend, or none
+// when they can't be read.
+func lastLogLines(backend Backend, n int) []string {
+	buffer := new(bytes.Buffer)
+	if err := backend.Logs(buffer); err != nil {
+		return nil
+	}
+	output := strings.TrimRight(buffer.String(), "\n")
+	if output == "" {
+		return nil
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// addHealthCheck makes the calls of the session fail with the error of a
+// container that stopped unexpectedly, before they are sent.
+func (ls *Localstack) addHealthCheck(sess *session.Session) *session.Session {
+	sess.Handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "localstack.HealthCheck",
+		Fn: func(r *request.Request) {
+			if err := ls.Err(); err != nil {
+				r.Error = err
+			}
+		},
+	})
+	return sess
+}
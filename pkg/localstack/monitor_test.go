@@ -0,0 +1,82 @@
+package localstack
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Monitor_ContainerExited(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	fake := &FakeBackend{
+		Info:   ContainerInfo{ExitCode: 137, OOMKilled: true},
+		Output: "Ready.\nstarting\nKilled\n",
+	}
+
+	ls, err := NewLocalstack(services, WithBackend(fake), WithMonitor(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Destroy()
+
+	ls.monitor.check()
+	if err := ls.Err(); err != nil {
+		t.Errorf("We were expecting no error while the container runs.  Received %v", err)
+	}
+
+	// The container dies.
+	fake.Stop()
+	ls.monitor.check()
+
+	svc, _ := ls.S3()
+	_, err = svc.ListBuckets(nil)
+	if !errors.Is(err, ErrContainerExited) {
+		t.Fatalf("We were expecting ErrContainerExited.  Received %v", err)
+	}
+	var exitErr *ContainerExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 137 || len(exitErr.Logs) != 3 {
+		t.Errorf("We were expecting the exit code and logs.  Received %#v", exitErr)
+	}
+	if !strings.Contains(err.Error(), "localstack container exited (OOMKilled)") || !strings.Contains(err.Error(), "Killed") {
+		t.Errorf("We were expecting a clear error.  Received %v", err)
+	}
+	if ls.Err() != err || ls.Restarts() != 0 {
+		t.Errorf("We were expecting Err to return the same error without restarts.  Received %v %d", ls.Err(), ls.Restarts())
+	}
+}
+
+func Test_Monitor_AutoRestart(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	fake := &FakeBackend{Output: "Ready."}
+
+	ls, err := NewLocalstack(services, WithBackend(fake), WithMonitor(time.Hour), WithAutoRestart(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Destroy()
+
+	fake.Stop()
+	ls.monitor.check()
+	if err := ls.Err(); err != nil || ls.Restarts() != 1 || fake.Stopped() {
+		t.Errorf("We were expecting the backend to be restarted.  Received %v %d", err, ls.Restarts())
+	}
+
+	fake.Stop()
+	ls.monitor.check()
+	if err := ls.Err(); !errors.Is(err, ErrContainerExited) || ls.Restarts() != 1 {
+		t.Errorf("We were expecting no more restarts.  Received %v %d", err, ls.Restarts())
+	}
+}
+
+func Test_lastLogLines(t *testing.T) {
+	fake := &FakeBackend{Output: "a\nb\nc\n"}
+	if lines := lastLogLines(fake, 2); len(lines) != 2 || lines[0] != "b" || lines[1] != "c" {
+		t.Errorf("We were expecting the last two lines.  Received %v", lines)
+	}
+	if lines := lastLogLines(&ExternalBackend{}, 2); lines != nil {
+		t.Errorf("We were expecting no lines without logs.  Received %v", lines)
+	}
+}
@@ -0,0 +1,286 @@
+package localstack
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Resource name limits of the services namespaces generate names for.
+const (
+	maxBucketName = 63
+	maxQueueName  = 80
+	maxTableName  = 255
+	maxTopicName  = 256
+	// maxNamespaceTestName is how much of the test name is kept in a prefix.
+	maxNamespaceTestName = 20
+)
+
+// Namespace generates resource names unique to one test, so tests sharing a
+// Localstack instance can run in parallel.  Everything created under its
+// prefix in the requested S3, SQS, DynamoDB and SNS services is removed when
+// the test finishes.
+type Namespace struct {
+	ls     *Localstack
+	prefix string
+}
+
+// Namespace returns a new namespace for the test.  Its prefix is made of the
+// test name and a random token, e.g. "testorders-9f86d081".
+func (ls *Localstack) Namespace(tb TB) *Namespace {
+	tb.Helper()
+
+	token := make([]byte, 4)
+	if _, err := rand.Read(token); err != nil {
+		tb.Fatalf("unable to generate a namespace: %s", err)
+	}
+	name := sanitizeName(strings.ToLower(tb.Name()), "-")
+	if len(name) > maxNamespaceTestName {
+		name = strings.Trim(name[:maxNamespaceTestName], "-")
+	}
+	if name == "" {
+		name = "test"
+	}
+
+	ns := &Namespace{ls: ls, prefix: fmt.Sprintf("%s-%s", name, hex.EncodeToString(token))}
+	tb.Cleanup(func() {
+		if err := ns.Purge(); err != nil {
+			tb.Errorf("unable to clean up namespace %s: %s", ns.prefix, err)
+		}
+	})
+	return ns
+}
+
+// Prefix returns the prefix of every name in the namespace.
+func (ns *Namespace) Prefix() string {
+	return ns.prefix
+}
+
+// Name returns a name in the namespace made of letters, numbers and hyphens.
+// It is at most 63 characters long, which suits most services.
+func (ns *Namespace) Name(base string) string {
+	return fitName(ns.prefix+"-"+sanitizeName(base, "-"), maxBucketName)
+}
+
+// Bucket returns an S3 bucket name in the namespace: lowercase letters,
+// numbers and hyphens, starting and ending with a letter or number, at most
+// 63 characters long.
+func (ns *Namespace) Bucket(base string) string {
+	return fitName(ns.prefix+"-"+sanitizeName(strings.ToLower(base), "-"), maxBucketName)
+}
+
+// Queue returns an SQS standard queue name in the namespace.
+func (ns *Namespace) Queue(base string) string {
+	return fitName(ns.prefix+"-"+sanitizeName(strings.TrimSuffix(base, ".fifo"), "_-"), maxQueueName)
+}
+
+// FIFOQueue returns an SQS FIFO queue name in the namespace, with the .fifo
+// suffix included in the length limit.
+func (ns *Namespace) FIFOQueue(base string) string {
+	return fitName(ns.prefix+"-"+sanitizeName(strings.TrimSuffix(base, ".fifo"), "_-"), maxQueueName-len(".fifo")) + ".fifo"
+}
+
+// Table returns a DynamoDB table name in the namespace.
+func (ns *Namespace) Table(base string) string {
+	return fitName(ns.prefix+"-"+sanitizeName(base, "_-."), maxTableName)
+}
+
+// Topic returns an SNS topic name in the namespace.
+func (ns *Namespace) Topic(base string) string {
+	return fitName(ns.prefix+"-"+sanitizeName(base, "_-"), maxTopicName)
+}
+
+// contains returns true when the resource name is in the namespace.
+func (ns *Namespace) contains(name string) bool {
+	return name == ns.prefix || strings.HasPrefix(name, ns.prefix+"-")
+}
+
+// sanitizeName replaces the characters that aren't ASCII letters, numbers or
+// one of allowed with the first of allowed, and trims them from both ends.
+func sanitizeName(name, allowed string) string {
+	replacement := rune(allowed[0])
+	sanitized := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(allowed, r) {
+			return r
+		}
+		return replacement
+	}, name)
+	return strings.Trim(sanitized, allowed)
+}
+
+// fitName shortens names over max characters, replacing their end with a hash
+// of the whole name so they stay unique.
+func fitName(name string, max int) string {
+	name = strings.TrimRight(name, "-_.")
+	if len(name) <= max {
+		return name
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(name)) //nolint:errcheck
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	return strings.TrimRight(name[:max-len(suffix)], "-_.") + suffix
+}
+
+// Purge removes everything in the namespace from the requested services.  It
+// is run automatically when the test finishes.
+func (ns *Namespace) Purge() error {
+	return purgeResources(ns.ls, ns.contains)
+}
+
+// resourcePurges remove the resources of the services purgeResources cleans.
+var resourcePurges = []struct {
+	service string
+	purge   func(*Localstack, func(string) bool) error
+}{
+	{"s3", purgeS3},
+	{"sqs", purgeSQS},
+	{"dynamodb", purgeDynamoDB},
+	{"sns", purgeSNS},
+}
+
+// purgeable returns true when purgeResources cleans every one of the services.
+func purgeable(services *LocalstackServiceCollection) bool {
+	for _, service := range *services {
+		found := false
+		for _, p := range resourcePurges {
+			if p.service == service.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// purgeResources removes the S3 buckets, SQS queues, DynamoDB tables and SNS
+// topics whose name matches from the requested services.
+func purgeResources(ls *Localstack, match func(name string) bool) error {
+	for _, p := range resourcePurges {
+		if !ls.Services.Contains(p.service) {
+			continue
+		}
+		if err := p.purge(ls, match); err != nil {
+			return fmt.Errorf("%s: %s", p.service, err)
+		}
+	}
+	return nil
+}
+
+func purgeS3(ls *Localstack, match func(string) bool) error {
+	svc, err := ls.S3()
+	if err != nil {
+		return err
+	}
+	buckets, err := svc.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets.Buckets {
+		if !match(aws.StringValue(bucket.Name)) {
+			continue
+		}
+		var deleteErr error
+		err := svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: bucket.Name},
+			func(page *s3.ListObjectsV2Output, _ bool) bool {
+				for _, object := range page.Contents {
+					if _, deleteErr = svc.DeleteObject(&s3.DeleteObjectInput{Bucket: bucket.Name, Key: object.Key}); deleteErr != nil {
+						return false
+					}
+				}
+				return true
+			})
+		if err != nil {
+			return err
+		}
+		if deleteErr != nil {
+			return deleteErr
+		}
+		if _, err := svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: bucket.Name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func purgeSQS(ls *Localstack, match func(string) bool) error {
+	svc, err := ls.SQS()
+	if err != nil {
+		return err
+	}
+	queues, err := svc.ListQueues(&sqs.ListQueuesInput{})
+	if err != nil {
+		return err
+	}
+	for _, url := range queues.QueueUrls {
+		name := aws.StringValue(url)
+		if !match(name[strings.LastIndex(name, "/")+1:]) {
+			continue
+		}
+		if _, err := svc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: url}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func purgeDynamoDB(ls *Localstack, match func(string) bool) error {
+	svc, err := ls.DynamoDB()
+	if err != nil {
+		return err
+	}
+	var tables []*string
+	err = svc.ListTablesPages(&dynamodb.ListTablesInput{}, func(page *dynamodb.ListTablesOutput, _ bool) bool {
+		for _, name := range page.TableNames {
+			if match(aws.StringValue(name)) {
+				tables = append(tables, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range tables {
+		if _, err := svc.DeleteTable(&dynamodb.DeleteTableInput{TableName: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func purgeSNS(ls *Localstack, match func(string) bool) error {
+	svc, err := ls.SNS()
+	if err != nil {
+		return err
+	}
+	var topics []*string
+	err = svc.ListTopicsPages(&sns.ListTopicsInput{}, func(page *sns.ListTopicsOutput, _ bool) bool {
+		for _, topic := range page.Topics {
+			arn := aws.StringValue(topic.TopicArn)
+			if match(arn[strings.LastIndex(arn, ":")+1:]) {
+				topics = append(topics, topic.TopicArn)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, arn := range topics {
+		if _, err := svc.DeleteTopic(&sns.DeleteTopicInput{TopicArn: arn}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package localstack
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// The test helpers must accept the testing package's types.
+var (
+	_ TB = (*testing.T)(nil)
+	_ TB = (*testing.B)(nil)
+)
+
+func Test_Namespace_Names(t *testing.T) {
+	ls := &Localstack{backend: newTestBackend(), Services: &LocalstackServiceCollection{}}
+	ns := ls.Namespace(t)
+	other := ls.Namespace(t)
+
+	if !strings.HasPrefix(ns.Prefix(), "test-namespace-names-") || ns.Prefix() == other.Prefix() {
+		t.Errorf("We were expecting unique prefixes made from the test name.  Received %s %s", ns.Prefix(), other.Prefix())
+	}
+
+	long := strings.Repeat("Orders Archive ", 20)
+	bucket := regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+	for _, name := range []string{ns.Bucket(long), ns.Bucket("My_Bucket.")} {
+		if !bucket.MatchString(name) {
+			t.Errorf("%q is not a valid bucket name.", name)
+		}
+	}
+	if name := ns.Name(long); len(name) > 63 || strings.Contains(name, " ") {
+		t.Errorf("%q is not a valid name.", name)
+	}
+	if ns.Bucket(long+"a") == ns.Bucket(long+"b") {
+		t.Error("Shortened names should stay unique.")
+	}
+
+	if name := ns.FIFOQueue(long + ".fifo"); len(name) > maxQueueName || !strings.HasSuffix(name, ".fifo") ||
+		strings.Count(name, ".") != 1 {
+		t.Errorf("%q is not a valid FIFO queue name.", name)
+	}
+	if name := ns.Queue("orders.fifo"); !regexp.MustCompile(`^[A-Za-z0-9_-]{1,80}$`).MatchString(name) {
+		t.Errorf("%q is not a valid queue name.", name)
+	}
+	if name := ns.Table("orders.v2"); !strings.HasSuffix(name, "-orders.v2") {
+		t.Errorf("Dots should be kept in table names.  Received %q", name)
+	}
+}
+
+func Test_Namespace_Purge(t *testing.T) {
+	ls := newMemoryLocalstack(t, "s3", "sqs", "dynamodb")
+	defer ls.Destroy()
+	s3svc, _ := ls.S3()
+	sqssvc, _ := ls.SQS()
+	dynamodbsvc, _ := ls.DynamoDB()
+
+	// Resources outside the namespace are left alone.
+	if _, err := s3svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("shared")}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("parallel", func(t *testing.T) {
+		ns := ls.Namespace(t)
+		bucket := ns.Bucket("uploads")
+		if _, err := s3svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s3svc.PutObject(&s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String("a")}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sqssvc.CreateQueue(&sqs.CreateQueueInput{QueueName: aws.String(ns.Queue("jobs"))}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := dynamodbsvc.CreateTable(&dynamodb.CreateTableInput{
+			TableName:            aws.String(ns.Table("orders")),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{{AttributeName: aws.String("id"), AttributeType: aws.String("S")}},
+			KeySchema:            []*dynamodb.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: aws.String("HASH")}},
+			BillingMode:          aws.String(dynamodb.BillingModePayPerRequest),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	buckets, _ := s3svc.ListBuckets(&s3.ListBucketsInput{})
+	if len(buckets.Buckets) != 1 || aws.StringValue(buckets.Buckets[0].Name) != "shared" {
+		t.Errorf("Only the shared bucket should be left.  Received %v", buckets.Buckets)
+	}
+	queues, _ := sqssvc.ListQueues(&sqs.ListQueuesInput{})
+	tables, _ := dynamodbsvc.ListTables(&dynamodb.ListTablesInput{})
+	if len(queues.QueueUrls) != 0 || len(tables.TableNames) != 0 {
+		t.Errorf("The queues and tables should be removed.  Received %v %v", queues.QueueUrls, tables.TableNames)
+	}
+}
@@ -0,0 +1,80 @@
+package localstack
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/dockertest/docker"
+)
+
+// Option configures optional behaviour of a Localstack instance.  Options are
+// passed to any of the NewLocalstack constructors and are applied in order.
+type Option func(*options)
+
+// options holds the optional configuration gathered from a list of Option values.
+type options struct {
+	// initScripts are the shell scripts copied into the container's
+	// initialisation hook directory.
+	initScripts initScripts
+	// initCallbacks are run, in order, once the container is ready.
+	initCallbacks []func(*Localstack) error
+	// seeds are run, in order, after the init callbacks.
+	seeds []seed
+	// ctx is passed to the seeds.
+	ctx context.Context
+	// strict disables the fallback to the real AWS endpoints.
+	strict bool
+	// record routes sessions through the recording proxy.
+	record bool
+	// recordingFile is where the recorded calls are written on Destroy.
+	recordingFile string
+	// injectFaults routes sessions through the fault injecting proxy.
+	injectFaults bool
+	// faults are the initial fault rules.
+	faults []FaultRule
+	// cassettePath is the cassette recorded or replayed, if any.
+	cassettePath string
+	// cassetteMode chooses whether the cassette is recorded or replayed.
+	cassetteMode CassetteMode
+	// backend stands in for the Localstack container, if set.
+	backend Backend
+	// hostConfigs adjust the host configuration of a new container.
+	hostConfigs []func(*docker.HostConfig)
+	// env holds extra environment variables of a new container.
+	env []string
+	// monitorInterval is how often the backend is checked, if at all.
+	monitorInterval time.Duration
+	// maxRestarts is how many times a stopped container is restarted.
+	maxRestarts int
+	// logger logs the lifecycle events, if set.
+	logger Logger
+	// eventHandlers are called with the lifecycle events.
+	eventHandlers []func(Event)
+	// readiness is how the Docker backend waits for Localstack.
+	readiness readinessPolicy
+	// labels are extra labels of a new container.
+	labels map[string]string
+	// keepOnFailure keeps the container of a failed test.
+	keepOnFailure bool
+}
+
+// newOptions applies each Option to a fresh set of options.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		initScripts: initScripts{target: InitHookDir},
+		ctx:         context.Background(),
+		strict:      runningInTest(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithContext sets the context passed to seed functions while the Localstack
+// instance is being created.  The default is context.Background().
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
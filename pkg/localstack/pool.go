@@ -0,0 +1,350 @@
+package localstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Acquire once the pool is closed.
+var ErrPoolClosed = errors.New("localstack pool is closed")
+
+// Pool keeps Localstack instances started for the same services ready, so
+// tests and test packages don't wait for a container to start.  Instances are
+// handed out by Acquire and given back with Release, which empties them for
+// the next user.  When every instance is busy, callers wait in turn.
+// Instances running services other than S3, SQS, DynamoDB and SNS can't be
+// emptied, so they are replaced rather than reused.
+type Pool struct {
+	services *LocalstackServiceCollection
+	// warm is how many idle instances the pool keeps ready.
+	warm int
+	// max is how many instances the pool holds, busy or not.
+	max int
+	// opts are the options every instance is created with.
+	opts []Option
+	// newInstance creates an instance.
+	newInstance func() (*Localstack, error)
+
+	mu sync.Mutex
+	// idle are the instances ready to be acquired.
+	idle []*Localstack
+	// total counts the instances that are idle, busy or starting.
+	total int
+	// starting counts the instances being started in the background.
+	starting int
+	// waiters are the callers of Acquire waiting for an instance, first in
+	// first served.
+	waiters []chan poolResult
+	closed  bool
+	// errs are the errors of destroying instances.
+	errs []error
+	// wg tracks the background starts and releases.
+	wg sync.WaitGroup
+}
+
+// poolResult is what a waiting caller of Acquire is handed.
+type poolResult struct {
+	ls  *Localstack
+	err error
+}
+
+// PoolOption configures a Pool.
+type PoolOption func(*Pool)
+
+// WithPoolSize sets how many idle instances the pool keeps ready.  The
+// default is one.
+func WithPoolSize(size int) PoolOption {
+	return func(p *Pool) {
+		p.warm = size
+	}
+}
+
+// WithPoolMaxSize sets how many instances the pool holds, busy or not.  The
+// default is the pool size.
+func WithPoolMaxSize(size int) PoolOption {
+	return func(p *Pool) {
+		p.max = size
+	}
+}
+
+// WithContainerMemory limits the memory of each container, in bytes.
+func WithContainerMemory(bytes int64) PoolOption {
+	return WithInstanceOptions(WithMemoryLimit(bytes))
+}
+
+// WithInstanceOptions sets the options every instance is created with.  They
+// must not share state between instances, as WithBackend would.
+func WithInstanceOptions(opts ...Option) PoolOption {
+	return func(p *Pool) {
+		p.opts = append(p.opts, opts...)
+	}
+}
+
+// NewPool returns a pool of instances running the given services and starts
+// the idle instances.  It returns once they are ready.
+func NewPool(services *LocalstackServiceCollection, opts ...PoolOption) (*Pool, error) {
+	p := &Pool{services: services, warm: 1}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.max < p.warm {
+		p.max = p.warm
+	}
+	if p.max < 1 {
+		return nil, fmt.Errorf("invalid pool size %d", p.max)
+	}
+	if p.newInstance == nil {
+		p.newInstance = func() (*Localstack, error) {
+			return NewLocalstack(p.services, p.opts...)
+		}
+	}
+	return p, p.warmUp()
+}
+
+// warmUp starts the idle instances and waits for them.
+func (p *Pool) warmUp() error {
+	var wg sync.WaitGroup
+	errs := make(chan error, p.warm)
+	p.mu.Lock()
+	p.total += p.warm
+	p.mu.Unlock()
+	for i := 0; i < p.warm; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ls, err := p.newInstance()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err != nil {
+				p.total--
+				errs <- err
+				return
+			}
+			p.idle = append(p.idle, ls)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		closeErr := p.Close()
+		if closeErr != nil {
+			return fmt.Errorf("%w (unable to close the pool: %s)", err, closeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Acquire returns an idle instance, starts a new one when the pool isn't
+// full, or waits for one to be released.  Waiting callers are served in the
+// order they arrived.
+func (p *Pool) Acquire(ctx context.Context) (*Localstack, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if len(p.waiters) == 0 {
+		if n := len(p.idle); n > 0 {
+			ls := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.fillLocked()
+			p.mu.Unlock()
+			return ls, nil
+		}
+		if p.total < p.max {
+			p.total++
+			p.mu.Unlock()
+			ls, err := p.newInstance()
+			if err != nil {
+				p.mu.Lock()
+				p.total--
+				p.mu.Unlock()
+				return nil, err
+			}
+			return ls, nil
+		}
+	}
+
+	waiter := make(chan poolResult, 1)
+	p.waiters = append(p.waiters, waiter)
+	p.fillLocked()
+	p.mu.Unlock()
+
+	select {
+	case result := <-waiter:
+		return result.ls, result.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		for i, w := range p.waiters {
+			if w == waiter {
+				p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+				break
+			}
+		}
+		p.mu.Unlock()
+		// An instance may have been handed over in the meantime.
+		select {
+		case result := <-waiter:
+			if result.ls != nil {
+				p.put(result.ls)
+			}
+		default:
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// AcquireForTest acquires an instance for the test and releases it when the
+// test finishes.  The test fails when no instance can be acquired.
+func (p *Pool) AcquireForTest(tb TB) *Localstack {
+	tb.Helper()
+	ls, err := p.Acquire(context.Background())
+	if err != nil {
+		tb.Fatalf("unable to acquire a localstack instance: %s", err)
+	}
+	tb.Cleanup(func() {
+		p.Release(ls)
+	})
+	return ls
+}
+
+// Release gives an instance back to the pool.  In the background, its
+// cleanups are run, every S3 bucket, SQS queue, DynamoDB table and SNS topic
+// is removed and its init callbacks and seeds are run again.  When that fails,
+// or when it runs a service whose resources can't be removed, the instance is
+// destroyed and replaced.  Instances released once the pool is closed are
+// destroyed.
+func (p *Pool) Release(ls *Localstack) {
+	p.mu.Lock()
+	if p.closed {
+		p.total--
+		p.mu.Unlock()
+		p.destroy(ls)
+		return
+	}
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	go func() {
+		defer p.wg.Done()
+		if err := reset(ls); err != nil {
+			p.recycle(ls)
+			return
+		}
+		p.put(ls)
+	}()
+}
+
+// reset empties a released instance and runs its init callbacks and seeds
+// again, so the next user finds it as it was created.
+func reset(ls *Localstack) error {
+	if err := ls.runCleanups(); err != nil {
+		return err
+	}
+	if !purgeable(ls.Services) {
+		return errors.New("the resources of some services can't be removed")
+	}
+	if err := purgeResources(ls, func(string) bool { return true }); err != nil {
+		return err
+	}
+	return ls.initialize()
+}
+
+// put hands an instance to the first waiting caller, or makes it idle.
+func (p *Pool) put(ls *Localstack) {
+	p.mu.Lock()
+	if p.closed {
+		p.total--
+		p.mu.Unlock()
+		p.destroy(ls)
+		return
+	}
+	defer p.mu.Unlock()
+	if len(p.waiters) > 0 {
+		waiter := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		waiter <- poolResult{ls: ls}
+		return
+	}
+	p.idle = append(p.idle, ls)
+}
+
+// recycle destroys an instance that couldn't be reset and starts another.
+func (p *Pool) recycle(ls *Localstack) {
+	p.destroy(ls)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total--
+	p.fillLocked()
+}
+
+// destroy destroys an instance, keeping the error for Close.
+func (p *Pool) destroy(ls *Localstack) {
+	if err := ls.Destroy(); err != nil {
+		p.mu.Lock()
+		p.errs = append(p.errs, err)
+		p.mu.Unlock()
+	}
+}
+
+// fillLocked starts instances in the background until enough are idle or
+// waited for, within the maximum size.  p.mu must be held.
+func (p *Pool) fillLocked() {
+	for !p.closed && p.total < p.max && len(p.idle)+p.starting < p.warm+len(p.waiters) {
+		p.total++
+		p.starting++
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			ls, err := p.newInstance()
+
+			p.mu.Lock()
+			p.starting--
+			if err != nil {
+				p.total--
+				// The first waiting caller is told, rather than waiting on.
+				if len(p.waiters) > 0 {
+					waiter := p.waiters[0]
+					p.waiters = p.waiters[1:]
+					waiter <- poolResult{err: err}
+				}
+				p.mu.Unlock()
+				return
+			}
+			p.mu.Unlock()
+			p.put(ls)
+		}()
+	}
+}
+
+// Close destroys the idle instances and waits for the background work to
+// finish.  Instances released later are destroyed.  Waiting callers of
+// Acquire get ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.total -= len(idle)
+	for _, waiter := range p.waiters {
+		waiter <- poolResult{err: ErrPoolClosed}
+	}
+	p.waiters = nil
+	p.mu.Unlock()
+
+	for _, ls := range idle {
+		p.destroy(ls)
+	}
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.errs) > 0 {
+		return fmt.Errorf("unable to destroy %d instances: %v", len(p.errs), p.errs)
+	}
+	return nil
+}
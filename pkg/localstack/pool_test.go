@@ -0,0 +1,226 @@
+package localstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// newMemoryPool returns a pool of in-memory instances.
+func newMemoryPool(t *testing.T, opts ...PoolOption) *Pool {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	opts = append(opts, func(p *Pool) {
+		p.newInstance = func() (*Localstack, error) {
+			opts := append([]Option{WithBackend(NewMemoryBackend())}, p.opts...)
+			return NewLocalstack(services, opts...)
+		}
+	})
+	pool, err := NewPool(services, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// waitForWaiters waits until n callers of Acquire are waiting.
+func waitForWaiters(t *testing.T, pool *Pool, n int) {
+	for i := 0; i < 100; i++ {
+		pool.mu.Lock()
+		waiting := len(pool.waiters)
+		pool.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("We were expecting %d waiting callers.", n)
+}
+
+func Test_Pool_ReleaseResets(t *testing.T) {
+	pool := newMemoryPool(t, WithPoolSize(1))
+	defer pool.Close()
+
+	ls, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, _ := ls.S3()
+	if _, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("leftover")}); err != nil {
+		t.Fatal(err)
+	}
+	var cleaned bool
+	ls.addCleanup(func() error {
+		cleaned = true
+		return nil
+	})
+	pool.Release(ls)
+
+	again, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != ls {
+		t.Fatal("We were expecting the released instance to be handed out again.")
+	}
+	buckets, _ := svc.ListBuckets(&s3.ListBucketsInput{})
+	if len(buckets.Buckets) != 0 || !cleaned {
+		t.Errorf("The instance should be reset on release.  Received %v", buckets.Buckets)
+	}
+	pool.Release(again)
+}
+
+func Test_Pool_ReleaseSeedsAgain(t *testing.T) {
+	seedBucket := func(ctx context.Context, ls *Localstack) error {
+		svc, err := ls.S3()
+		if err != nil {
+			return err
+		}
+		_, err = svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("seeded")})
+		return err
+	}
+	pool := newMemoryPool(t, WithPoolSize(1), WithPoolMaxSize(1), WithInstanceOptions(WithSeed(seedBucket)))
+	defer pool.Close()
+
+	for i := 0; i < 2; i++ {
+		ls, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		svc, _ := ls.S3()
+		if _, err := svc.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String("seeded")}); err != nil {
+			t.Errorf("We were expecting the seeded bucket on acquire %d.  Received %v", i+1, err)
+		}
+		pool.Release(ls)
+	}
+}
+
+func Test_Pool_ReleaseReplacesUnpurgeable(t *testing.T) {
+	ssm, _ := NewLocalstackService("ssm")
+	services := &LocalstackServiceCollection{*ssm}
+	var backends []*FakeBackend
+	pool, err := NewPool(services, WithPoolSize(1), WithPoolMaxSize(1), func(p *Pool) {
+		p.newInstance = func() (*Localstack, error) {
+			backend := &FakeBackend{}
+			p.mu.Lock()
+			backends = append(backends, backend)
+			p.mu.Unlock()
+			return NewLocalstack(services, WithBackend(backend))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	ls, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Release(ls)
+	again, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == ls || !backends[0].Stopped() {
+		t.Error("We were expecting an instance running ssm to be replaced rather than reused.")
+	}
+	pool.Release(again)
+}
+
+func Test_Pool_ReleaseKeepsProxy(t *testing.T) {
+	pool := newMemoryPool(t, WithPoolSize(1), WithInstanceOptions(WithRecorder()))
+	defer pool.Close()
+
+	for i := 0; i < 2; i++ {
+		ls, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		svc, _ := ls.S3()
+		if _, err := svc.ListBuckets(&s3.ListBucketsInput{}); err != nil {
+			t.Fatalf("The proxy should outlive the release of the instance.  Received %v", err)
+		}
+		pool.Release(ls)
+	}
+}
+
+func Test_Pool_WarmUpFailure(t *testing.T) {
+	failed := errors.New("no docker")
+	pool, err := NewPool(&LocalstackServiceCollection{}, WithPoolSize(2), func(p *Pool) {
+		p.newInstance = func() (*Localstack, error) {
+			return nil, failed
+		}
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("We were expecting the start error.  Received %v", err)
+	}
+	if pool.total != 0 {
+		t.Errorf("We were expecting the failed instances not to be counted.  Received %d", pool.total)
+	}
+}
+
+func Test_Pool_FairWaiting(t *testing.T) {
+	pool := newMemoryPool(t, WithPoolSize(1), WithPoolMaxSize(1))
+	defer pool.Close()
+
+	ls, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func(i int) {
+			acquired, err := pool.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			pool.Release(acquired)
+		}(i)
+		waitForWaiters(t, pool, i)
+	}
+
+	pool.Release(ls)
+	if first, second := <-order, <-order; first != 1 || second != 2 {
+		t.Errorf("The callers should be served in the order they arrived.  Received %d %d", first, second)
+	}
+}
+
+func Test_Pool_AcquireCanceled(t *testing.T) {
+	pool := newMemoryPool(t, WithPoolSize(1), WithPoolMaxSize(1))
+
+	ls, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("We were expecting the deadline to be exceeded.  Received %v", err)
+	}
+
+	go func() {
+		waitForWaiters(t, pool, 1)
+		if err := pool.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if _, err := pool.Acquire(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("We were expecting ErrPoolClosed.  Received %v", err)
+	}
+
+	// Instances released after the pool is closed are destroyed.
+	pool.Release(ls)
+	pool.wg.Wait()
+	if info, _ := ls.Backend().Inspect(); info.Running {
+		t.Error("The released instance should be destroyed.")
+	}
+}
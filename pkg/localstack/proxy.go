@@ -0,0 +1,325 @@
+package localstack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// edgeProxy is an in-process HTTP proxy that sits between sessions created for
+// a Localstack instance and the Localstack edge port.  Every request passing
+// through the proxy is described by a Call, which is handed to the recorder.
+type edgeProxy struct {
+	listener net.Listener
+	server   *http.Server
+	upstream http.Handler
+	recorder *Recorder
+	faults   *FaultInjector
+	// cassette is the cassette forwarded calls are recorded to, if any.
+	cassette *Cassette
+	// recordingFile is where the recorded calls are written when the proxy
+	// is finished, if anywhere.
+	recordingFile string
+}
+
+// The AWS protocols a request can be made with.
+const (
+	protocolJSON     = "json"
+	protocolQuery    = "query"
+	protocolRESTXML  = "rest-xml"
+	protocolRESTJSON = "rest-json"
+)
+
+// reverseProxy returns a handler forwarding requests to the target URL.
+func reverseProxy(target string) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edge url %s: %s", target, err)
+	}
+	return httputil.NewSingleHostReverseProxy(u), nil
+}
+
+// newEdgeProxy starts a proxy on a random local port handing requests to the
+// upstream handler.  The recorder and fault injector are optional.
+func newEdgeProxy(upstream http.Handler, recorder *Recorder, faults *FaultInjector) (*edgeProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to start proxy: %s", err)
+	}
+
+	p := &edgeProxy{
+		listener: listener,
+		upstream: upstream,
+		recorder: recorder,
+		faults:   faults,
+	}
+	p.server = &http.Server{Handler: p}
+	go p.server.Serve(listener) //nolint:errcheck
+
+	return p, nil
+}
+
+// startProxy routes the instance's sessions through a new proxy that records
+// calls, injects faults and records or replays a cassette as requested by the
+// options.  The proxy is finished when the instance is destroyed, not when
+// its cleanups are run, so it outlives the release of pooled instances.
+func (ls *Localstack) startProxy(o *options) error {
+	var recorder *Recorder
+	if o.record {
+		recorder = &Recorder{}
+	}
+	var faults *FaultInjector
+	if o.injectFaults {
+		faults = newFaultInjector(o.faults...)
+	}
+
+	var cassette *Cassette
+	var upstream http.Handler
+	if o.cassettePath != "" {
+		var err error
+		if cassette, err = openCassette(o.cassettePath, o.cassetteMode); err != nil {
+			return err
+		}
+	}
+	if cassette != nil && cassette.Mode() == CassetteReplay {
+		upstream = http.HandlerFunc(cassette.replay)
+	} else {
+		var err error
+		if upstream, err = reverseProxy(ls.containerURL()); err != nil {
+			return err
+		}
+	}
+
+	p, err := newEdgeProxy(upstream, recorder, faults)
+	if err != nil {
+		return err
+	}
+	p.cassette = cassette
+	p.recordingFile = o.recordingFile
+	ls.proxy = p
+	return nil
+}
+
+// finish writes the recorded calls to the recording file, finishes the
+// cassette and closes the proxy.
+func (p *edgeProxy) finish() error {
+	var err error
+	if p.recordingFile != "" {
+		err = p.recorder.DumpJSONLines(p.recordingFile)
+	}
+	if p.cassette != nil {
+		if cassetteErr := p.cassette.finish(); err == nil {
+			err = cassetteErr
+		}
+	}
+	if closeErr := p.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// URL returns the address sessions should send their requests to.
+func (p *edgeProxy) URL() string {
+	return fmt.Sprintf("http://%s", p.listener.Addr().String())
+}
+
+// Close stops the proxy.
+func (p *edgeProxy) Close() error {
+	return p.server.Shutdown(context.Background())
+}
+
+// ServeHTTP describes the request, forwards it to Localstack and records the result.
+func (p *edgeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	call := describeCall(r, body)
+	start := time.Now()
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	recording := p.cassette != nil && p.cassette.Mode() == CassetteRecord
+	if recording {
+		// Responses are recorded uncompressed so they can be rewritten on replay.
+		r.Header.Del("Accept-Encoding")
+		sw.body = new(bytes.Buffer)
+	}
+	handled := false
+	if p.faults != nil {
+		if rule := p.faults.next(&call); rule != nil {
+			call.Injected = true
+			handled = rule.inject(sw, r, &call)
+		}
+	}
+	if !handled {
+		p.upstream.ServeHTTP(sw, r)
+		if recording {
+			p.cassette.record(r.Host, call, sw.Header(), sw.status, sw.body.Bytes())
+		}
+	}
+
+	call.Status = sw.status
+	call.Latency = time.Since(start)
+	if p.recorder != nil {
+		p.recorder.record(call)
+	}
+}
+
+// statusWriter remembers the status code written to a response, and the body
+// when body isn't nil.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.body != nil {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack allows dropped connections to be simulated.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response can't be hijacked")
+	}
+	w.status = 0
+	return hijacker.Hijack()
+}
+
+// credentialScope matches the service in the credential scope of a SigV4
+// Authorization header. (I.E. "Credential=a/20200901/us-east-1/dynamodb/aws4_request")
+var credentialScope = regexp.MustCompile(`Credential=[^/]*/[^/]*/[^/]*/([^/]*)/aws4_request`)
+
+// describeCall works out the service, operation and parameters of an AWS request.
+func describeCall(r *http.Request, body []byte) Call {
+	call := Call{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+	}
+
+	if match := credentialScope.FindStringSubmatch(r.Header.Get("Authorization")); match != nil {
+		call.Service = match[1]
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case r.Header.Get("X-Amz-Target") != "":
+		// JSON protocol. (I.E. "DynamoDB_20120810.PutItem")
+		call.protocol = protocolJSON
+		target := r.Header.Get("X-Amz-Target")
+		call.Operation = target[strings.LastIndex(target, ".")+1:]
+		params := map[string]interface{}{}
+		if err := json.Unmarshal(body, &params); err == nil {
+			call.Params = params
+		}
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		// Query protocol. (I.E. "Action=CreateQueue&QueueName=jobs")
+		call.protocol = protocolQuery
+		values, err := url.ParseQuery(string(body))
+		if err == nil {
+			call.Operation = values.Get("Action")
+			call.Params = flattenValues(values, "Action", "Version")
+		}
+	case call.Service == "s3":
+		call.protocol = protocolRESTXML
+		call.Operation, call.Params = describeS3Call(r)
+	default:
+		// REST protocols, described by their method and path.
+		call.protocol = protocolRESTJSON
+		call.Operation = fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		if len(r.URL.Query()) > 0 {
+			call.Params = flattenValues(r.URL.Query())
+		}
+	}
+
+	return call
+}
+
+// flattenValues converts url.Values into parameters, skipping the given keys.
+func flattenValues(values url.Values, skip ...string) map[string]interface{} {
+	params := map[string]interface{}{}
+	for key, value := range values {
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || key == s
+		}
+		if skipped {
+			continue
+		}
+		if len(value) == 1 {
+			params[key] = value[0]
+		} else {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+// describeS3Call names the common S3 operations of a path style request.
+func describeS3Call(r *http.Request) (string, map[string]interface{}) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	params := flattenValues(r.URL.Query())
+	bucket := parts[0]
+	if bucket == "" {
+		return "ListBuckets", params
+	}
+	params["Bucket"] = bucket
+
+	if len(parts) == 1 || parts[1] == "" {
+		operations := map[string]string{
+			http.MethodPut:    "CreateBucket",
+			http.MethodGet:    "ListObjects",
+			http.MethodHead:   "HeadBucket",
+			http.MethodDelete: "DeleteBucket",
+		}
+		return s3Operation(operations, r), params
+	}
+
+	params["Key"] = parts[1]
+	operations := map[string]string{
+		http.MethodPut:    "PutObject",
+		http.MethodGet:    "GetObject",
+		http.MethodHead:   "HeadObject",
+		http.MethodDelete: "DeleteObject",
+	}
+	return s3Operation(operations, r), params
+}
+
+// s3Operation looks up the operation for the request method, falling back to
+// the method and path for anything else. (I.E. multipart uploads)
+func s3Operation(operations map[string]string, r *http.Request) string {
+	query := r.URL.Query()
+	_, uploads := query["uploads"]
+	_, uploadID := query["uploadId"]
+	if operation, ok := operations[r.Method]; ok && !uploads && !uploadID {
+		if operation == "ListObjects" && query.Get("list-type") == "2" {
+			return "ListObjectsV2"
+		}
+		return operation
+	}
+	return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+}
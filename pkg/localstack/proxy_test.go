@@ -0,0 +1,110 @@
+package localstack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func Test_describeCall(t *testing.T) {
+	tests := []struct {
+		method, target, contentType, path, body string
+		service, operation, param, value        string
+	}{
+		{
+			method: http.MethodPost, target: "DynamoDB_20120810.PutItem", path: "/",
+			body:    `{"TableName": "users"}`,
+			service: "dynamodb", operation: "PutItem", param: "TableName", value: "users",
+		},
+		{
+			method: http.MethodPost, contentType: "application/x-www-form-urlencoded; charset=utf-8", path: "/",
+			body:    "Action=CreateQueue&QueueName=jobs&Version=2012-11-05",
+			service: "sqs", operation: "CreateQueue", param: "QueueName", value: "jobs",
+		},
+		{
+			method: http.MethodPut, path: "/examplebucket/some/key",
+			service: "s3", operation: "PutObject", param: "Key", value: "some/key",
+		},
+		{
+			method: http.MethodGet, path: "/examplebucket?list-type=2",
+			service: "s3", operation: "ListObjectsV2", param: "Bucket", value: "examplebucket",
+		},
+		{
+			method: http.MethodGet, path: "/2015-03-31/functions/",
+			service: "lambda", operation: "GET /2015-03-31/functions/",
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		r.Header.Set("Authorization",
+			"AWS4-HMAC-SHA256 Credential=a/20200901/us-east-1/"+test.service+"/aws4_request, SignedHeaders=host, Signature=x")
+		if test.target != "" {
+			r.Header.Set("X-Amz-Target", test.target)
+		}
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+
+		call := describeCall(r, []byte(test.body))
+		if call.Service != test.service || call.Operation != test.operation {
+			t.Errorf("Expected %s %s.  Received %s %s", test.service, test.operation, call.Service, call.Operation)
+		}
+		if test.param != "" && call.Params[test.param] != test.value {
+			t.Errorf("Expected %s to be %s.  Received %v", test.param, test.value, call.Params)
+		}
+	}
+}
+
+func Test_edgeProxy_Records(t *testing.T) {
+	edge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte("{}")) //nolint:errcheck
+	}))
+	defer edge.Close()
+
+	dynamo, _ := NewLocalstackService("dynamodb")
+	ls := &Localstack{
+		backend:  newTestBackend(),
+		Services: &LocalstackServiceCollection{*dynamo},
+	}
+	p, err := newEdgeProxy(mustReverseProxy(t, edge.URL), &Recorder{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ls.proxy = p
+
+	svc, err := ls.DynamoDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("users"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ls.Recorder().Filter("dynamodb", "PutItem")
+	if len(calls) != 1 {
+		t.Fatalf("We were expecting exactly one PutItem.  Received %v", ls.Recorder().Calls())
+	}
+	if calls[0].Params["TableName"] != "users" || calls[0].Status != http.StatusOK {
+		t.Errorf("The call was not recorded correctly.  Received %+v", calls[0])
+	}
+}
+
+// mustReverseProxy returns a handler forwarding to the target URL.
+func mustReverseProxy(t *testing.T, target string) http.Handler {
+	upstream, err := reverseProxy(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return upstream
+}
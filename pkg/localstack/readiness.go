@@ -0,0 +1,192 @@
+package localstack
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Default readiness policy, the same as dockertest's.
+const (
+	defaultReadinessTimeout     = 5 * time.Minute
+	defaultReadinessInterval    = 500 * time.Millisecond
+	defaultReadinessMaxInterval = 5 * time.Second
+)
+
+// ReadinessStrategy decides whether Localstack is ready for the requested
+// services once its container is running.  The default waits for "Ready."
+// in the logs.
+type ReadinessStrategy interface {
+	// Ready returns nil once the backend is ready, or why it isn't yet.  It
+	// is waited on for each requested service in turn, with a collection
+	// holding only that service.
+	Ready(backend Backend, services *LocalstackServiceCollection) error
+}
+
+// ReadinessFunc is a ReadinessStrategy made of a function.
+type ReadinessFunc func(backend Backend, services *LocalstackServiceCollection) error
+
+// Ready calls f.
+func (f ReadinessFunc) Ready(backend Backend, services *LocalstackServiceCollection) error {
+	return f(backend, services)
+}
+
+// LogPattern is ready once a line of the logs matches pattern.
+func LogPattern(pattern *regexp.Regexp) ReadinessStrategy {
+	return ReadinessFunc(func(backend Backend, _ *LocalstackServiceCollection) error {
+		buffer := new(bytes.Buffer)
+		if err := backend.Logs(buffer); err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(buffer)
+		for scanner.Scan() {
+			if pattern.MatchString(scanner.Text()) {
+				return nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("reading logs: %s", err)
+		}
+		return fmt.Errorf("no log line matches %s", pattern)
+	})
+}
+
+// HTTPHealth is ready once the Localstack health endpoint reports the
+// requested services as running.  Versions without the endpoint are ready
+// once it answers.
+func HTTPHealth() ReadinessStrategy {
+	return ReadinessFunc(func(backend Backend, services *LocalstackServiceCollection) error {
+		return NewExternalBackend(backend.Endpoint()).ready(services)
+	})
+}
+
+// TCPPort is ready once the edge port accepts connections.  This only shows
+// the container is listening, not that the services are ready.
+func TCPPort() ReadinessStrategy {
+	return ReadinessFunc(func(backend Backend, _ *LocalstackServiceCollection) error {
+		endpoint, err := url.Parse(backend.Endpoint())
+		if err != nil {
+			return fmt.Errorf("invalid endpoint %s: %s", backend.Endpoint(), err)
+		}
+		conn, err := net.DialTimeout("tcp", endpoint.Host, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// AllOf is ready once every strategy is, checked in order.
+func AllOf(strategies ...ReadinessStrategy) ReadinessStrategy {
+	return ReadinessFunc(func(backend Backend, services *LocalstackServiceCollection) error {
+		for _, strategy := range strategies {
+			if err := strategy.Ready(backend, services); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AnyOf is ready as soon as one of the strategies is.
+func AnyOf(strategies ...ReadinessStrategy) ReadinessStrategy {
+	return ReadinessFunc(func(backend Backend, services *LocalstackServiceCollection) error {
+		reasons := make([]string, 0, len(strategies))
+		for _, strategy := range strategies {
+			err := strategy.Ready(backend, services)
+			if err == nil {
+				return nil
+			}
+			reasons = append(reasons, err.Error())
+		}
+		return errors.New(strings.Join(reasons, "; "))
+	})
+}
+
+// readinessPolicy is how the Docker backend waits for Localstack.
+type readinessPolicy struct {
+	// strategy replaces waiting for "Ready." in the logs, if set.
+	strategy ReadinessStrategy
+	// timeout, interval and maxInterval replace dockertest's retries when
+	// any of them is set.
+	timeout     time.Duration
+	interval    time.Duration
+	maxInterval time.Duration
+}
+
+// WithReadinessStrategy sets how the Docker backend decides Localstack is
+// ready.  Strategies are combined with AllOf and AnyOf.
+func WithReadinessStrategy(strategy ReadinessStrategy) Option {
+	return func(o *options) {
+		o.readiness.strategy = strategy
+	}
+}
+
+// WithReadinessTimeout sets how long the Docker backend waits for Localstack
+// to be ready.  The default is five minutes.
+func WithReadinessTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readiness.timeout = timeout
+	}
+}
+
+// WithReadinessBackoff sets the interval between readiness checks of the
+// Docker backend.  It starts at initial and doubles up to max.  The default
+// is 500ms up to 5s.
+func WithReadinessBackoff(initial, max time.Duration) Option {
+	return func(o *options) {
+		o.readiness.interval = initial
+		o.readiness.maxInterval = max
+	}
+}
+
+// custom returns true when the timeout or back-off was set.
+func (p readinessPolicy) custom() bool {
+	return p.timeout > 0 || p.interval > 0 || p.maxInterval > 0
+}
+
+// deadline returns when the timeout started now is over.
+func (p readinessPolicy) deadline() time.Time {
+	return time.Now().Add(p.timeoutOrDefault())
+}
+
+// timeoutOrDefault returns the timeout, or the default when it wasn't set.
+func (p readinessPolicy) timeoutOrDefault() time.Duration {
+	if p.timeout <= 0 {
+		return defaultReadinessTimeout
+	}
+	return p.timeout
+}
+
+// retry calls op until it succeeds or the deadline is passed, waiting longer
+// between each call.  The waits of one start share a deadline, so the timeout
+// bounds them all.  It returns the last error of op.
+func (p readinessPolicy) retry(deadline time.Time, op func() error) error {
+	interval, maxInterval := p.interval, p.maxInterval
+	if interval <= 0 {
+		interval = defaultReadinessInterval
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+
+	for {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return fmt.Errorf("not ready after %s: %s", p.timeoutOrDefault(), err)
+		}
+		time.Sleep(interval)
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_ReadinessStrategies(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{*s3, *sqs}
+	health := `{"services": {"s3": "running", "sqs": "starting"}}`
+	fake := &FakeBackend{
+		Output: "Starting mock S3 service\n",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, health)
+		}),
+	}
+	if err := fake.Start(services); err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Stop()
+
+	notReady := ReadinessFunc(func(Backend, *LocalstackServiceCollection) error {
+		return errors.New("custom")
+	})
+	tests := []struct {
+		name     string
+		strategy ReadinessStrategy
+		ready    bool
+	}{
+		{"log pattern matches", LogPattern(regexp.MustCompile(`mock S3`)), true},
+		{"log pattern doesn't match", LogPattern(regexp.MustCompile(`^Ready\.$`)), false},
+		{"service still starting", HTTPHealth(), false},
+		{"tcp port", TCPPort(), true},
+		{"custom", notReady, false},
+		{"all of", AllOf(TCPPort(), notReady), false},
+		{"any of", AnyOf(notReady, TCPPort()), true},
+		{"none of", AnyOf(notReady, HTTPHealth()), false},
+	}
+	for _, test := range tests {
+		err := test.strategy.Ready(fake, services)
+		if (err == nil) != test.ready {
+			t.Errorf("%s: we were expecting ready to be %t.  Received %v", test.name, test.ready, err)
+		}
+	}
+
+	health = `{"services": {"s3": "running", "sqs": "available"}}`
+	if err := HTTPHealth().Ready(fake, services); err != nil {
+		t.Errorf("We were expecting the services to be ready.  Received %v", err)
+	}
+	if err := AnyOf(notReady, notReady).Ready(fake, services); err == nil || err.Error() != "custom; custom" {
+		t.Errorf("We were expecting every reason.  Received %v", err)
+	}
+}
+
+func Test_readinessPolicy_retry(t *testing.T) {
+	policy := readinessPolicy{timeout: 50 * time.Millisecond, interval: time.Millisecond, maxInterval: 4 * time.Millisecond}
+	calls := 0
+	err := policy.retry(policy.deadline(), func() error {
+		calls++
+		return errors.New("not Ready")
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "not ready after 50ms") || calls < 5 {
+		t.Errorf("We were expecting the retries to time out.  Received %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = policy.retry(policy.deadline(), func() error {
+		if calls++; calls < 3 {
+			return errors.New("not Ready")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("We were expecting the third call to succeed.  Received %v after %d calls", err, calls)
+	}
+}
+
+func Test_NewLocalstack_ReadinessTimeoutSharedByServices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	sns, _ := NewLocalstackService("sns")
+	services := &LocalstackServiceCollection{
+		*sqs,
+		*sns,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		Return(&dockertest.Resource{Container: &docker.Container{}}, nil)
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		AnyTimes().
+		Return(nil)
+
+	// Each service is ready 150ms after the previous one, so the second is
+	// only ready once the 200ms timeout of the start is over.
+	ready := time.Now()
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithReadinessTimeout(200*time.Millisecond),
+		WithReadinessBackoff(time.Millisecond, time.Millisecond),
+		WithReadinessStrategy(ReadinessFunc(func(backend Backend, s *LocalstackServiceCollection) error {
+			if time.Since(ready) < 150*time.Millisecond {
+				return errors.New("not Ready")
+			}
+			ready = time.Now()
+			return nil
+		})))
+	if err == nil || !strings.Contains(err.Error(), "unable to connect to sns") {
+		t.Errorf("We were expecting the timeout to cover every service.  Received %v", err)
+	}
+}
+
+func Test_NewLocalstack_ReadinessPerService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	sns, _ := NewLocalstackService("sns")
+	services := &LocalstackServiceCollection{
+		*sqs,
+		*sns,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		Return(&dockertest.Resource{Container: &docker.Container{}}, nil)
+
+	var checked []string
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithReadinessTimeout(time.Second),
+		WithReadinessStrategy(ReadinessFunc(func(backend Backend, s *LocalstackServiceCollection) error {
+			for _, service := range *s {
+				checked = append(checked, service.Name)
+			}
+			return nil
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(checked, ",") != "sqs,sns" {
+		t.Errorf("We were expecting the strategy to be checked once for each service.  Received %v", checked)
+	}
+}
+
+func Test_NewLocalstack_ReadinessOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		Return(&dockertest.Resource{Container: &docker.Container{}}, nil)
+
+	// The readiness timeout replaces dockertest's retries.
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(0)
+
+	checks := 0
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithReadinessTimeout(time.Second),
+		WithReadinessBackoff(time.Millisecond, time.Millisecond),
+		WithReadinessStrategy(ReadinessFunc(func(backend Backend, s *LocalstackServiceCollection) error {
+			if checks++; checks < 3 {
+				return errors.New("not Ready")
+			}
+			return nil
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks != 3 {
+		t.Errorf("We were expecting the strategy to be checked until ready.  Received %d checks", checks)
+	}
+}
@@ -0,0 +1,136 @@
+package localstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Call describes a single AWS request sent to Localstack.
+type Call struct {
+	// Time is when the request was received.
+	Time time.Time `json:"time"`
+	// Service is the signing name of the service. (I.E. "dynamodb" or "s3")
+	Service string `json:"service"`
+	// Operation is the API operation. (I.E. "PutItem")  Requests to REST
+	// services that can't be named are described by their method and path.
+	Operation string `json:"operation"`
+	// Method is the HTTP method of the request.
+	Method string `json:"method"`
+	// Path is the HTTP path of the request.
+	Path string `json:"path"`
+	// Params are the parameters of the request.
+	Params map[string]interface{} `json:"params,omitempty"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Latency is how long Localstack took to respond.
+	Latency time.Duration `json:"latency"`
+	// Injected is true when the response came from a fault rule rather than
+	// Localstack.  See WithFaultInjection.
+	Injected bool `json:"injected,omitempty"`
+
+	// protocol is the AWS protocol the request was made with.
+	protocol string
+}
+
+// Recorder records the AWS calls sent to a Localstack instance.
+// See WithRecorder.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (r *Recorder) record(call Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+// Calls returns every recorded call, oldest first.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := make([]Call, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+// Filter returns the recorded calls to the given service and operation.  An
+// empty service or operation matches any.
+func (r *Recorder) Filter(service, operation string) []Call {
+	return r.Find(func(call Call) bool {
+		return (service == "" || call.Service == service) && (operation == "" || call.Operation == operation)
+	})
+}
+
+// Find returns the recorded calls the given function returns true for.
+func (r *Recorder) Find(match func(Call) bool) []Call {
+	var calls []Call
+	for _, call := range r.Calls() {
+		if match(call) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets every recorded call.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// WriteJSONLines writes every recorded call to w as one JSON object per line.
+func (r *Recorder) WriteJSONLines(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, call := range r.Calls() {
+		if err := encoder.Encode(call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DumpJSONLines writes every recorded call to the file at path as JSON lines.
+func (r *Recorder) DumpJSONLines(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %s", path, err)
+	}
+	if err := r.WriteJSONLines(f); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %s", path, err)
+	}
+	return f.Close()
+}
+
+// WithRecorder routes every session created for the Localstack instance through
+// an in-process proxy that records the AWS calls made.  The calls are available
+// from Localstack.Recorder.
+func WithRecorder() Option {
+	return func(o *options) {
+		o.record = true
+	}
+}
+
+// WithRecordingFile is the same as WithRecorder, and also writes the recorded
+// calls to the file at path as JSON lines when the instance is destroyed.
+func WithRecordingFile(path string) Option {
+	return func(o *options) {
+		o.record = true
+		o.recordingFile = path
+	}
+}
+
+// Recorder returns the recorder of the AWS calls sent to the instance, or nil
+// when recording wasn't requested.  See WithRecorder.
+func (ls *Localstack) Recorder() *Recorder {
+	if ls.proxy == nil {
+		return nil
+	}
+	return ls.proxy.recorder
+}
@@ -0,0 +1,47 @@
+package localstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_Recorder(t *testing.T) {
+	r := &Recorder{}
+	r.record(Call{Service: "sqs", Operation: "CreateQueue", Status: 200})
+	r.record(Call{Service: "sqs", Operation: "SendMessage", Status: 200})
+	r.record(Call{Service: "s3", Operation: "PutObject", Status: 500})
+
+	if len(r.Filter("sqs", "")) != 2 {
+		t.Error("We were expecting two sqs calls.")
+	}
+	if len(r.Filter("", "PutObject")) != 1 {
+		t.Error("We were expecting one PutObject call.")
+	}
+	failed := r.Find(func(call Call) bool { return call.Status >= 500 })
+	if len(failed) != 1 || failed[0].Service != "s3" {
+		t.Errorf("We were expecting the failed s3 call.  Received %v", failed)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := r.WriteJSONLines(buffer); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("We were expecting three lines.  Received %d", len(lines))
+	}
+	var call Call
+	if err := json.Unmarshal([]byte(lines[1]), &call); err != nil {
+		t.Fatal(err)
+	}
+	if call.Operation != "SendMessage" {
+		t.Errorf("The calls should be written in order.  Received %s", call.Operation)
+	}
+
+	r.Reset()
+	if len(r.Calls()) != 0 {
+		t.Error("Reset should forget every call.")
+	}
+}
@@ -0,0 +1,151 @@
+package localstack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reportLogLines is how many of the last log lines a failed startup report
+// holds.
+const reportLogLines = 50
+
+// StartupReport describes how an instance started, to tell why starting it
+// was slow or failed.
+type StartupReport struct {
+	// Pull is the time spent pulling the image.
+	Pull time.Duration
+	// Create is the time spent creating the container.
+	Create time.Duration
+	// Wait is the time spent waiting for the services to be ready.
+	Wait time.Duration
+	// Total is the time the whole startup took.
+	Total time.Duration
+	// Reused is true when a running container was reused.
+	Reused bool
+	// Services holds the last known status of each requested service, as
+	// reported by the readiness checks or the Localstack health endpoint.
+	Services map[string]string
+	// Container is the state of the container when startup failed.
+	Container *ContainerInfo
+	// Logs are the last lines written by the container when startup failed.
+	Logs []string
+
+	// waiting is the time spent so far on the service not yet ready.
+	waiting time.Duration
+}
+
+// String formats the report for an error message or a test log.
+func (r *StartupReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "startup report: pull %s, create %s, wait %s, total %s",
+		r.Pull, r.Create, r.Wait, r.Total)
+	if r.Reused {
+		b.WriteString(" (container reused)")
+	}
+	if c := r.Container; c != nil {
+		fmt.Fprintf(&b, "\n  container %s", c.Name)
+		switch {
+		case c.Running:
+			b.WriteString(" is running")
+		case c.OOMKilled:
+			fmt.Fprintf(&b, " exited with code %d (OOMKilled)", c.ExitCode)
+		default:
+			fmt.Fprintf(&b, " exited with code %d", c.ExitCode)
+		}
+		if c.Error != "" {
+			fmt.Fprintf(&b, ": %s", c.Error)
+		}
+	}
+	for _, name := range sortedKeys(r.Services) {
+		fmt.Fprintf(&b, "\n  %s: %s", name, r.Services[name])
+	}
+	if len(r.Logs) > 0 {
+		b.WriteString("\n  last log lines:")
+		for _, line := range r.Logs {
+			fmt.Fprintf(&b, "\n    %s", line)
+		}
+	}
+	return b.String()
+}
+
+// StartupError is returned when an instance fails to start.  It holds the
+// report of the startup so far.
+type StartupError struct {
+	Err    error
+	Report *StartupReport
+}
+
+func (e *StartupError) Error() string {
+	return fmt.Sprintf("%s\n%s", e.Err, e.Report)
+}
+
+// Unwrap returns the error the startup failed with.
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+// StartupReport returns the report of how the instance started.  The state
+// of the container and its logs are only collected when startup fails.
+func (ls *Localstack) StartupReport() *StartupReport {
+	return ls.report
+}
+
+// newStartupReport returns a report with every service's status unknown.
+func newStartupReport(services *LocalstackServiceCollection) *StartupReport {
+	report := &StartupReport{Services: map[string]string{}}
+	if services != nil {
+		for _, service := range *services {
+			report.Services[service.Name] = "unknown"
+		}
+	}
+	return report
+}
+
+// record adds the timings and statuses of an event to the report.
+func (r *StartupReport) record(e Event) {
+	switch e.Type {
+	case EventImagePulled:
+		r.Pull += e.Duration
+	case EventContainerCreated:
+		r.Create += e.Duration
+	case EventContainerReused:
+		r.Reused = true
+	case EventReadinessRetry:
+		r.waiting = e.Duration
+		r.Services[e.Service] = fmt.Sprintf("not ready after %d checks (%s)", e.Attempt, e.Err)
+	case EventServiceReady:
+		r.waiting = 0
+		r.Wait += e.Duration
+		r.Services[e.Service] = "ready"
+	}
+}
+
+// finish completes the report once startup is over.  The time spent on a
+// service that never became ready counts as waiting.
+func (r *StartupReport) finish(total time.Duration) {
+	r.Wait += r.waiting
+	r.waiting = 0
+	r.Total = total
+}
+
+// diagnose collects the state, health and logs of a backend that failed to
+// start.
+func (r *StartupReport) diagnose(backend Backend) {
+	info, err := backend.Inspect()
+	if err != nil {
+		return
+	}
+	r.Container = info
+	r.Logs = lastLogLines(backend, reportLogLines)
+	if !info.Running {
+		return
+	}
+	if health, err := NewExternalBackend(backend.Endpoint()).health(); err == nil {
+		for name, status := range health.Services {
+			if _, ok := r.Services[name]; ok {
+				r.Services[name] = status
+			}
+		}
+	}
+}
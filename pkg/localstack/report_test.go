@@ -0,0 +1,106 @@
+package localstack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ory/dockertest"
+	"github.com/ory/dockertest/docker"
+)
+
+func Test_NewLocalstack_StartupReport_ReadinessFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{
+		*sqs,
+		*s3,
+	}
+	m := getLocalstackEmpty(services, ctrl)
+
+	m.
+		EXPECT().
+		RunWithOptions(gomock.Any()).
+		Times(1).
+		Return(&dockertest.Resource{Container: &docker.Container{ID: "container"}}, nil)
+
+	m.
+		EXPECT().
+		Logs(gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(opts docker.LogsOptions) error {
+			fmt.Fprint(opts.OutputStream, "Starting mock S3 service\nKilled\n")
+			return nil
+		})
+
+	notReady := errors.New("not Ready")
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(op func() error) error {
+			op()
+			op()
+			return notReady
+		})
+
+	m.
+		EXPECT().
+		InspectContainer("container").
+		Times(1).
+		Return(&docker.Container{
+			ID:    "container",
+			Name:  "/localstack",
+			State: docker.State{ExitCode: 137, OOMKilled: true},
+		}, nil)
+
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag)
+
+	var startupErr *StartupError
+	if !errors.As(err, &startupErr) {
+		t.Fatalf("We were expecting a StartupError.  Received %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "unable to connect to sqs") {
+		t.Errorf("We were expecting the readiness error first.  Received %v", err)
+	}
+	report := startupErr.Report
+	if report.Container == nil || !report.Container.OOMKilled || report.Container.ExitCode != 137 {
+		t.Errorf("We were expecting the container state.  Received %+v", report.Container)
+	}
+	if !strings.HasPrefix(report.Services["sqs"], "not ready after 2 checks") || report.Services["s3"] != "unknown" {
+		t.Errorf("We were expecting the service statuses.  Received %v", report.Services)
+	}
+	if len(report.Logs) != 2 || report.Logs[1] != "Killed" {
+		t.Errorf("We were expecting the tail of the logs.  Received %v", report.Logs)
+	}
+	if report.Wait <= 0 || report.Total < report.Wait {
+		t.Errorf("We were expecting the waiting time.  Received %s of %s", report.Wait, report.Total)
+	}
+	for _, expected := range []string{"container localstack exited with code 137 (OOMKilled)", "s3: unknown", "    Killed"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("We were expecting %q in the error.  Received %v", expected, err)
+		}
+	}
+}
+
+func Test_NewLocalstack_StartupReport(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+
+	ls, err := NewLocalstack(services, WithBackend(&FakeBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Destroy()
+
+	report := ls.StartupReport()
+	if report == nil || report.Services["s3"] != "ready" || report.Total <= 0 || report.Container != nil {
+		t.Errorf("We were expecting the report of a successful startup.  Received %+v", report)
+	}
+}
@@ -0,0 +1,52 @@
+package localstack
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// SeedFunc populates a ready Localstack instance with fixtures, for example
+// creating the tables, buckets and queues a test suite relies on.
+type SeedFunc func(context.Context, *Localstack) error
+
+// seed is a SeedFunc along with the name used when reporting its failure.
+type seed struct {
+	name string
+	fn   SeedFunc
+}
+
+// WithSeed registers a function that seeds the Localstack instance once it is
+// ready.  Seeds are run in the order they are given, before the constructor
+// returns.  If a seed fails the container is destroyed and the returned error
+// names the failing seed.  The name is taken from the function itself; use
+// WithNamedSeed for anonymous functions.
+func WithSeed(fn SeedFunc) Option {
+	return WithNamedSeed(funcName(fn), fn)
+}
+
+// WithNamedSeed is the same as WithSeed but uses the given name when
+// reporting a failure.  A nil seed makes the constructor fail.
+func WithNamedSeed(name string, fn SeedFunc) Option {
+	return func(o *options) {
+		o.seeds = append(o.seeds, seed{name: name, fn: fn})
+	}
+}
+
+// funcName returns the short name of a function. (I.E. "examples.SeedTables")
+func funcName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.IsNil() {
+		return "<nil>"
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
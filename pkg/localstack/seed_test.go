@@ -0,0 +1,128 @@
+package localstack
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+type seedContextKey struct{}
+
+func seedTables(context.Context, *Localstack) error {
+	return errors.New("dummy Error")
+}
+
+func Test_NewLocalstack_Seeds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m, _ := getLocalstackFound(services, ctrl)
+
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(0)
+
+	ctx := context.WithValue(context.Background(), seedContextKey{}, "value")
+	var calls []string
+	_, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithContext(ctx),
+		WithNamedSeed("first", func(c context.Context, _ *Localstack) error {
+			if c.Value(seedContextKey{}) != "value" {
+				t.Error("The seed should receive the context given with WithContext.")
+			}
+			calls = append(calls, "first")
+			return nil
+		}),
+		WithNamedSeed("second", func(context.Context, *Localstack) error {
+			calls = append(calls, "second")
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(calls, ",") != "first,second" {
+		t.Errorf("The seeds were not run in order.  Received %v", calls)
+	}
+}
+
+func Test_NewLocalstack_SeedFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+
+	sqs, _ := NewLocalstackService("sqs")
+	services := &LocalstackServiceCollection{
+		*sqs,
+	}
+	m, _ := getLocalstackFound(services, ctrl)
+
+	m.
+		EXPECT().
+		Retry(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	m.
+		EXPECT().
+		Purge(gomock.Any()).
+		Times(1).
+		Return(errors.New("purge Error"))
+
+	ran := false
+	result, err := newLocalstack(services, m, LocalstackName, LocalstackRepository, LocalstackTag,
+		WithSeed(seedTables),
+		WithSeed(func(context.Context, *Localstack) error {
+			ran = true
+			return nil
+		}))
+
+	if result != nil {
+		t.Error("We were expecting the returned container to be nil.")
+	}
+
+	if err == nil {
+		t.Fatal("We were expecting the returned error to be populated.")
+	}
+
+	if !strings.Contains(err.Error(), "localstack.seedTables") {
+		t.Errorf("The error should name the failing seed.  Received %s", err)
+	}
+
+	if !strings.Contains(err.Error(), "purge Error") {
+		t.Errorf("The error should include the teardown failure.  Received %s", err)
+	}
+
+	if ran {
+		t.Error("Seeds after a failure should not be run.")
+	}
+}
+
+func Test_NewLocalstack_NilSeed(t *testing.T) {
+	s3, _ := NewLocalstackService("s3")
+	services := &LocalstackServiceCollection{*s3}
+	fake := &FakeBackend{}
+
+	_, err := NewLocalstack(services, WithBackend(fake), WithSeed(nil))
+	if err == nil || err.Error() != "seed <nil> has no function" {
+		t.Errorf("We were expecting a nil seed to be rejected.  Received %v", err)
+	}
+	if !fake.Stopped() {
+		t.Error("The backend should be stopped when a seed is nil.")
+	}
+}
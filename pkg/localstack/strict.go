@@ -0,0 +1,74 @@
+package localstack
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// The static credentials used by every session created for Localstack.
+const (
+	testAccessKeyID     = "a"
+	testSecretAccessKey = "b"
+	testSessionToken    = "c"
+)
+
+// ErrRealAWSCredentials is returned, in strict mode, when a session could pick
+// up real AWS credentials.
+var ErrRealAWSCredentials = errors.New("real AWS credentials are in use")
+
+// realAWSEnvironment are the environment variables that point the AWS SDK at a
+// real profile or role.
+var realAWSEnvironment = []string{
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_ROLE_ARN",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+	"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+}
+
+// WithStrictEndpoints turns strict mode on or off.  In strict mode EndpointFor
+// returns an error for any service that isn't routed to Localstack, instead of
+// falling back to the real AWS endpoint, and sessions refuse to be created
+// while a real AWS profile or role is configured.  Strict mode is on by
+// default when running under go test.
+func WithStrictEndpoints(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+// runningInTest returns true when the current binary was built by go test.
+func runningInTest() bool {
+	return flag.Lookup("test.v") != nil
+}
+
+// checkAWSEnvironment returns an error when the environment points the AWS SDK
+// at a real profile or role.
+func checkAWSEnvironment() error {
+	for _, name := range realAWSEnvironment {
+		if os.Getenv(name) != "" {
+			return fmt.Errorf("%s is set, unset it or disable strict mode with WithStrictEndpoints(false): %w",
+				name, ErrRealAWSCredentials)
+		}
+	}
+	return nil
+}
+
+// checkSessionCredentials returns an error when the session doesn't use the
+// static Localstack credentials.
+func checkSessionCredentials(sess *session.Session) error {
+	value, err := sess.Config.Credentials.Get()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve session credentials: %s", err)
+	}
+	if value.ProviderName != credentials.StaticProviderName || value.AccessKeyID != testAccessKeyID {
+		return fmt.Errorf("the session uses credentials from %s: %w", value.ProviderName, ErrRealAWSCredentials)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package localstack
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+func Test_newOptions_StrictInTests(t *testing.T) {
+	if !newOptions().strict {
+		t.Error("Strict mode should be on by default under go test.")
+	}
+	if newOptions(WithStrictEndpoints(false)).strict {
+		t.Error("WithStrictEndpoints(false) should turn strict mode off.")
+	}
+}
+
+func Test_EndpointFor_Strict(t *testing.T) {
+	sqs, _ := NewLocalstackService("sqs")
+	ls := &Localstack{
+		backend:  newTestBackend(),
+		Services: &LocalstackServiceCollection{*sqs},
+		strict:   true,
+	}
+
+	ep, err := ls.EndpointFor(endpoints.SqsServiceID, "us-east-1")
+	if err != nil || ep.URL != defaultURL {
+		t.Errorf("A requested service should be routed to localstack.  Received %s, %v", ep.URL, err)
+	}
+
+	if _, err := ls.EndpointFor(endpoints.S3ServiceID, "us-east-1"); !errors.Is(err, ErrServiceNotRequested) {
+		t.Errorf("We were expecting ErrServiceNotRequested.  Received %v", err)
+	}
+
+	ls.strict = false
+	ep, err = ls.EndpointFor(endpoints.S3ServiceID, "us-east-1")
+	if err != nil || ep.URL == defaultURL {
+		t.Errorf("Without strict mode the default resolver should be used.  Received %s, %v", ep.URL, err)
+	}
+}
+
+func Test_NewAWSSession_Strict(t *testing.T) {
+	ls := &Localstack{
+		backend:  newTestBackend(),
+		Services: &LocalstackServiceCollection{},
+		strict:   true,
+	}
+
+	if _, err := ls.NewAWSSession(); err != nil {
+		t.Fatalf("We were not expecting an error.  Received %s", err)
+	}
+
+	old, set := os.LookupEnv("AWS_PROFILE")
+	os.Setenv("AWS_PROFILE", "production")
+	defer func() {
+		if set {
+			os.Setenv("AWS_PROFILE", old)
+		} else {
+			os.Unsetenv("AWS_PROFILE")
+		}
+	}()
+
+	if _, err := ls.NewAWSSession(); !errors.Is(err, ErrRealAWSCredentials) {
+		t.Errorf("We were expecting ErrRealAWSCredentials.  Received %v", err)
+	}
+
+	ls.strict = false
+	if _, err := ls.NewAWSSession(); err != nil {
+		t.Errorf("Without strict mode the profile should be ignored.  Received %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package localstack
+
+// TB is the part of testing.TB used by the test helpers, e.g.
+// Localstack.Namespace.  *testing.T and *testing.B satisfy it.  It is declared
+// here rather than importing testing, which would register the test flags in
+// every binary using this package.
+type TB interface {
+	Helper()
+	Name() string
+	Fatalf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+	Failed() bool
+	Log(args ...interface{})
+}